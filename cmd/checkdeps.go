@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/montag451/go-pypi-mirror/pkg"
+)
+
+var sysPlatforms = map[string][2]string{
+	"linux":  {"Linux", "posix"},
+	"darwin": {"Darwin", "posix"},
+	"win32":  {"Windows", "nt"},
+	"cygwin": {"CYGWIN_NT", "posix"},
+}
+
+func markerEnv(pythonVersion, platform, machine, implementation string) (map[string]string, error) {
+	components := strings.Split(pythonVersion, ".")
+	if len(components) < 2 {
+		return nil, fmt.Errorf("invalid Python version %q, expected at least major.minor", pythonVersion)
+	}
+	fullVersion := pythonVersion
+	if len(components) == 2 {
+		fullVersion += ".0"
+	}
+	system, ok := sysPlatforms[platform]
+	if !ok {
+		return nil, fmt.Errorf("unknown platform %q", platform)
+	}
+	implementationName := strings.ToLower(implementation)
+	pythonImplementation := implementation
+	if implementationName == "cpython" {
+		pythonImplementation = "CPython"
+	} else if implementationName == "pypy" {
+		pythonImplementation = "PyPy"
+	}
+	env := map[string]string{
+		"os_name":                        system[1],
+		"sys_platform":                   platform,
+		"platform_machine":               machine,
+		"platform_python_implementation": pythonImplementation,
+		"platform_release":               "",
+		"platform_system":                system[0],
+		"platform_version":               "",
+		"python_version":                 strings.Join(components[:2], "."),
+		"python_full_version":            fullVersion,
+		"implementation_name":            implementationName,
+		"implementation_version":         fullVersion,
+		"extra":                          "",
+	}
+	return env, nil
+}
+
+type checkDepsCommand struct {
+	flags          *flag.FlagSet
+	downloadDir    string
+	pythonVersion  string
+	platform       string
+	machine        string
+	implementation string
+	workers        int
+}
+
+func (c *checkDepsCommand) FlagSet() *flag.FlagSet {
+	return c.flags
+}
+
+func (c *checkDepsCommand) Execute(ctx context.Context) error {
+	env, err := markerEnv(c.pythonVersion, c.platform, c.machine, c.implementation)
+	if err != nil {
+		return err
+	}
+	pkgs, err := pkg.List(ctx, c.downloadDir, true, c.workers)
+	if err != nil {
+		return err
+	}
+	byNormName := make(map[string][]*pkg.Pkg)
+	for _, group := range pkg.GroupByNormName(pkgs) {
+		byNormName[group.Key.(string)] = group.Pkgs
+	}
+	type item struct {
+		pkg   *pkg.Pkg
+		extra string
+	}
+	queue := make([]item, 0, len(pkgs))
+	seen := make(map[item]bool, len(pkgs))
+	for _, p := range pkgs {
+		it := item{p, ""}
+		queue = append(queue, it)
+		seen[it] = true
+	}
+	reported := make(map[string]bool)
+	unsatisfied := 0
+	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		it := queue[0]
+		queue = queue[1:]
+		meta, err := it.pkg.ReadCoreMetadata()
+		if err != nil {
+			if errors.Is(err, pkg.ErrNoCoreMetadata) {
+				continue
+			}
+			return err
+		}
+		env["extra"] = it.extra
+		for _, rawReq := range meta.RequiresDist {
+			req, err := pkg.ParseRequirement(rawReq)
+			if err != nil {
+				log.Printf("%s: %v", it.pkg.Filename, err)
+				continue
+			}
+			if req.Marker != nil {
+				ok, err := req.Marker.Evaluate(env)
+				if err != nil {
+					log.Printf("%s: failed to evaluate marker of %q: %v", it.pkg.Filename, rawReq, err)
+					continue
+				}
+				if !ok {
+					continue
+				}
+			}
+			var candidates []*pkg.Pkg
+			for _, p := range byNormName[req.NormName()] {
+				ok, err := pkg.MatchSpecifier(req.Specifier, p.Metadata.Version)
+				if err != nil {
+					log.Printf("%s: %v", p.Filename, err)
+					continue
+				}
+				if ok {
+					candidates = append(candidates, p)
+				}
+			}
+			if len(candidates) == 0 {
+				key := it.pkg.Filename + "\x00" + rawReq
+				if !reported[key] {
+					reported[key] = true
+					unsatisfied++
+					fmt.Printf("%s: unsatisfied requirement %q\n", it.pkg.Filename, rawReq)
+				}
+				continue
+			}
+			for _, extra := range req.Extras {
+				for _, p := range candidates {
+					next := item{p, pkg.Normalize(extra)}
+					if !seen[next] {
+						seen[next] = true
+						queue = append(queue, next)
+					}
+				}
+			}
+		}
+	}
+	if unsatisfied > 0 {
+		return fmt.Errorf("%d unsatisfied requirement(s)", unsatisfied)
+	}
+	return nil
+}
+
+func init() {
+	cmd := checkDepsCommand{}
+	flags := flag.NewFlagSet("check-deps", flag.ExitOnError)
+	flags.StringVar(&cmd.downloadDir, "download-dir", ".", "download dir")
+	flags.StringVar(&cmd.pythonVersion, "python-version", "3.8", "target Python version")
+	flags.StringVar(&cmd.platform, "platform", "linux", "target platform as reported by sys.platform")
+	flags.StringVar(&cmd.machine, "machine", "x86_64", "target machine as reported by platform.machine()")
+	flags.StringVar(&cmd.implementation, "implementation", "cpython", "target Python implementation")
+	flags.IntVar(&cmd.workers, "jobs", pkg.DefaultWorkers(), "number of packages processed concurrently")
+	cmd.flags = flags
+	RegisterCommand(&cmd)
+}
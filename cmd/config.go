@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/montag451/go-pypi-mirror/internal/toml"
+)
+
+type syncConfig struct {
+	downloadDir     string
+	mirrorDir       string
+	jobs            int
+	indexURL        string
+	proxy           string
+	native          bool
+	pip             string
+	packages        []string
+	requirements    []string
+	locks           []string
+	allowBinary     bool
+	noDeps          bool
+	keepLatest      int
+	keep            string
+	dropPrereleases bool
+	copy            bool
+	generations     int
+	targetsFile     string
+	queryURL        string
+	projects        []trackedProject
+}
+
+type trackedProject struct {
+	name        string
+	constraints string
+}
+
+func (c *syncConfig) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"download-dir":               &c.downloadDir,
+		"mirror-dir":                 &c.mirrorDir,
+		"jobs":                       &c.jobs,
+		"source.index-url":           &c.indexURL,
+		"source.proxy":               &c.proxy,
+		"source.native":              &c.native,
+		"source.pip":                 &c.pip,
+		"source.query-url":           &c.queryURL,
+		"packages.names":             &c.packages,
+		"packages.requirements":      &c.requirements,
+		"packages.locks":             &c.locks,
+		"packages.allow-binary":      &c.allowBinary,
+		"packages.no-deps":           &c.noDeps,
+		"retention.keep-latest":      &c.keepLatest,
+		"retention.keep":             &c.keep,
+		"retention.drop-prereleases": &c.dropPrereleases,
+		"output.copy":                &c.copy,
+		"output.generations":         &c.generations,
+	}
+}
+
+func setConfigValue(ptr interface{}, v interface{}) error {
+	switch ptr := ptr.(type) {
+	case *string:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("expected a string, got %v", v)
+		}
+		*ptr = s
+	case *bool:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("expected a boolean, got %v", v)
+		}
+		*ptr = b
+	case *int:
+		i, ok := v.(int64)
+		if !ok {
+			return fmt.Errorf("expected an integer, got %v", v)
+		}
+		*ptr = int(i)
+	case *[]string:
+		values, err := stringList(v)
+		if err != nil {
+			return err
+		}
+		*ptr = values
+	default:
+		return fmt.Errorf("unsupported type %T", ptr)
+	}
+	return nil
+}
+
+func copyConfigValue(dest interface{}, src interface{}) {
+	switch dest := dest.(type) {
+	case *string:
+		*dest = *src.(*string)
+	case *bool:
+		*dest = *src.(*bool)
+	case *int:
+		*dest = *src.(*int)
+	case *[]string:
+		*dest = *src.(*[]string)
+	}
+}
+
+func flattenConfig(prefix string, t map[string]interface{}, flat map[string]interface{}) {
+	for k, v := range t {
+		if table, ok := v.(map[string]interface{}); ok {
+			flattenConfig(prefix+k+".", table, flat)
+			continue
+		}
+		flat[prefix+k] = v
+	}
+}
+
+func loadSyncConfig(path string) (*syncConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	doc, err := toml.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", path, err)
+	}
+	c := &syncConfig{}
+	if _, ok := doc["matrix"]; ok {
+		c.targetsFile = path
+	}
+	if _, ok := doc["target"]; ok {
+		c.targetsFile = path
+	}
+	projects, _ := doc["project"].([]interface{})
+	for i, e := range projects {
+		table, ok := e.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: project %d is not a table", path, i)
+		}
+		var p trackedProject
+		fields := map[string]interface{}{"name": &p.name, "constraints": &p.constraints}
+		for k, v := range table {
+			ptr, ok := fields[k]
+			if !ok {
+				return nil, fmt.Errorf("%s: project %d: unknown key %q", path, i, k)
+			}
+			if err := setConfigValue(ptr, v); err != nil {
+				return nil, fmt.Errorf("%s: project %d: invalid value for %q: %w", path, i, k, err)
+			}
+		}
+		if p.name == "" {
+			return nil, fmt.Errorf("%s: project %d: missing name", path, i)
+		}
+		c.projects = append(c.projects, p)
+	}
+	delete(doc, "matrix")
+	delete(doc, "target")
+	delete(doc, "project")
+	flat := make(map[string]interface{})
+	flattenConfig("", doc, flat)
+	fields := c.fields()
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		ptr, ok := fields[k]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown key %q", path, k)
+		}
+		if err := setConfigValue(ptr, flat[k]); err != nil {
+			return nil, fmt.Errorf("%s: invalid value for %q: %w", path, k, err)
+		}
+	}
+	dir := filepath.Dir(path)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	c.downloadDir = resolve(c.downloadDir)
+	c.mirrorDir = resolve(c.mirrorDir)
+	for i := range c.requirements {
+		c.requirements[i] = resolve(c.requirements[i])
+	}
+	for i := range c.locks {
+		c.locks[i] = resolve(c.locks[i])
+	}
+	return c, nil
+}
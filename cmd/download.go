@@ -1,14 +1,21 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/montag451/go-pypi-mirror/internal/flagutil"
+	"github.com/montag451/go-pypi-mirror/internal/pypi"
 	"github.com/montag451/go-pypi-mirror/pkg"
 )
 
@@ -25,17 +32,118 @@ type downloadCommand struct {
 	noBuildIsolation bool
 	abi              flagutil.StringSlice
 	pip              string
+	workers          int
+	native           bool
+	noDeps           bool
+	locks            flagutil.StringSlice
+	target           flagutil.StringSlice
+	targetsFile      string
 }
 
 func (c *downloadCommand) FlagSet() *flag.FlagSet {
 	return c.flags
 }
 
-func (c *downloadCommand) Execute(context.Context) error {
-	pkgs := c.FlagSet().Args()
-	if len(pkgs) == 0 && len(c.requirements) == 0 {
-		return errors.New("at least one requirements file or package must be specified")
+func (c *downloadCommand) targets() ([]*downloadTarget, error) {
+	base := &downloadTarget{
+		pythonVersion:  c.pythonVersion,
+		implementation: c.implementation,
+		platforms:      c.platform,
+		abis:           c.abi,
 	}
+	var targets []*downloadTarget
+	if c.targetsFile != "" {
+		fileTargets, err := loadTargets(c.targetsFile)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, fileTargets...)
+	}
+	for _, s := range c.target {
+		t, err := parseTarget(s)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		return []*downloadTarget{base}, nil
+	}
+	for _, t := range targets {
+		t.inherit(base)
+	}
+	return targets, nil
+}
+
+func (c *downloadCommand) Execute(ctx context.Context) error {
+	return c.download(ctx, c.FlagSet().Args())
+}
+
+func (c *downloadCommand) download(ctx context.Context, pkgs []string) error {
+	if len(pkgs) == 0 && len(c.requirements) == 0 && len(c.locks) == 0 {
+		return errors.New("at least one requirements file, lock file or package must be specified")
+	}
+	targets, err := c.targets()
+	if err != nil {
+		return err
+	}
+	native := c.native || len(c.locks) > 0
+	var n *nativeDownload
+	if native {
+		if n, err = c.newNativeDownload(pkgs); err != nil {
+			return err
+		}
+	}
+	served := make(map[string][]string)
+	var files []string
+	for _, t := range targets {
+		if len(targets) > 1 {
+			fmt.Printf("Downloading for target %s\n", t)
+		}
+		var targetFiles []string
+		if native {
+			targetFiles, err = n.run(ctx, t)
+		} else {
+			targetFiles, err = c.executePip(ctx, pkgs, t)
+		}
+		if err != nil {
+			return err
+		}
+		for _, f := range targetFiles {
+			if _, ok := served[f]; !ok {
+				files = append(files, f)
+			}
+			served[f] = append(served[f], t.String())
+		}
+	}
+	if !native {
+		if err := c.createMetadataFiles(ctx); err != nil {
+			return err
+		}
+	}
+	if len(targets) > 1 {
+		sort.Strings(files)
+		for _, f := range files {
+			fmt.Printf("%s: %s\n", f, strings.Join(served[f], " "))
+		}
+	}
+	return nil
+}
+
+func parsePipOutput(output string) []string {
+	var files []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		for _, prefix := range []string{"Saved ", "File was already downloaded "} {
+			if strings.HasPrefix(line, prefix) {
+				files = append(files, filepath.Base(strings.TrimPrefix(line, prefix)))
+			}
+		}
+	}
+	return files
+}
+
+func (c *downloadCommand) executePip(ctx context.Context, pkgs []string, t *downloadTarget) ([]string, error) {
 	args := make([]string, 0, 3+len(pkgs)+2*len(c.requirements))
 	args = append(args, "download", "-d", c.dest)
 	if c.indexUrl != "" {
@@ -47,43 +155,207 @@ func (c *downloadCommand) Execute(context.Context) error {
 	if !c.allowBinary {
 		args = append(args, "--no-binary", ":all:")
 	}
-	if len(c.platform) > 0 || c.pythonVersion != "" || c.implementation != "" || len(c.abi) > 0 {
+	if t.binaryOnly() {
 		args = append(args, "--only-binary", ":all:")
 	}
-	for _, p := range c.platform {
+	for _, p := range t.platforms {
 		args = append(args, "--platform", p)
 	}
-	if c.pythonVersion != "" {
-		args = append(args, "--python-version", c.pythonVersion)
+	if t.pythonVersion != "" {
+		args = append(args, "--python-version", t.pythonVersion)
 	}
-	if c.implementation != "" {
-		args = append(args, "--implementation", c.implementation)
+	if t.implementation != "" {
+		args = append(args, "--implementation", t.implementation)
 	}
 	if c.noBuildIsolation {
 		args = append(args, "--no-build-isolation")
 	}
-	for _, a := range c.abi {
+	for _, a := range t.abis {
 		args = append(args, "--abi", a)
 	}
 	for _, r := range c.requirements {
 		args = append(args, "-r", r)
 	}
 	args = append(args, pkgs...)
-	cmd := exec.Command(c.pip, args...)
-	cmd.Stdout = os.Stdout
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.pip, args...)
+	cmd.Stdout = io.MultiWriter(os.Stdout, &output)
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failure while executing %q: %w", cmd, err)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("failure while executing %q: %w", cmd, err)
+	}
+	return parsePipOutput(output.String()), nil
+}
+
+func writeMetadataFile(client *pypi.Client, p *pkg.Pkg, file *pypi.File) error {
+	if file != nil {
+		info, err := os.Stat(p.Path)
+		if err != nil {
+			return err
+		}
+		p.Metadata.Source = client.Source(file, info.ModTime())
+	}
+	return pkg.WriteMetadataFile(p)
+}
+
+func (c *downloadCommand) createMetadataFiles(ctx context.Context) error {
+	pkgs, err := pkg.List(ctx, c.dest, true, c.workers)
+	if err != nil {
+		return err
+	}
+	client, err := pypi.NewClient(c.indexUrl, c.proxy)
+	if err != nil {
+		return err
+	}
+	failures := 0
+	for _, group := range pkg.GroupByNormName(pkgs) {
+		var missing []*pkg.Pkg
+		for _, p := range group.Pkgs {
+			if _, err := os.Stat(p.MetadataPath()); errors.Is(err, os.ErrNotExist) {
+				missing = append(missing, p)
+			} else if p.Metadata.Stale() {
+				if err := pkg.WriteMetadataFile(p); err != nil {
+					return err
+				}
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		files := make(map[string]*pypi.File)
+		project, err := client.Project(ctx, missing[0].Metadata.Name)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("unable to look up provenance of %s: %v", missing[0].Metadata.Name, err)
+		} else {
+			for _, f := range project.Files {
+				files[f.Filename] = f
+			}
+		}
+		for _, p := range missing {
+			if err := writeMetadataFile(client, p, files[p.Filename]); err != nil {
+				if !errors.Is(err, pkg.ErrHashMismatch) {
+					return err
+				}
+				log.Print(err)
+				if err := p.Remove(); err != nil {
+					return err
+				}
+				log.Printf("removed %s", p.Path)
+				failures++
+			}
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d file(s) don't match their upstream digest and were removed", failures)
+	}
+	return nil
+}
+
+type nativeDownload struct {
+	cmd    *downloadCommand
+	client *pypi.Client
+	locked []*pkg.LockedPackage
+	reqs   []*pkg.Requirement
+}
+
+func (c *downloadCommand) newNativeDownload(pkgs []string) (*nativeDownload, error) {
+	if c.noBuildIsolation {
+		log.Printf("-no-build-isolation has no effect in native mode")
+	}
+	n := &nativeDownload{cmd: c}
+	for _, l := range c.locks {
+		lockPkgs, err := pkg.ParseLockFile(l)
+		if err != nil {
+			return nil, err
+		}
+		n.locked = append(n.locked, lockPkgs...)
+	}
+	for _, r := range c.requirements {
+		fileReqs, err := pkg.ParseRequirementsFile(r)
+		if err != nil {
+			return nil, err
+		}
+		n.reqs = append(n.reqs, fileReqs...)
+	}
+	for _, p := range pkgs {
+		req, err := pkg.ParseRequirement(p)
+		if err != nil {
+			return nil, err
+		}
+		n.reqs = append(n.reqs, req)
+	}
+	client, err := pypi.NewClient(c.indexUrl, c.proxy)
+	if err != nil {
+		return nil, err
+	}
+	n.client = client
+	if err := os.MkdirAll(c.dest, 0755); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *nativeDownload) run(ctx context.Context, t *downloadTarget) ([]string, error) {
+	var tags pkg.TagSet
+	if t.binaryOnly() {
+		if t.pythonVersion == "" {
+			return nil, errors.New("-python-version must be specified along with -platform, -implementation or -abi in native mode")
+		}
+		var err error
+		tags, err = pkg.SupportedTags(t.pythonVersion, t.implementation, t.abis, t.platforms)
+		if err != nil {
+			return nil, err
+		}
+	} else if t.pythonVersion == "" {
+		if n.cmd.allowBinary {
+			return nil, errors.New("-python-version must be specified along with -allow-binary in native mode")
+		}
+		log.Printf("no Python version specified, evaluating environment markers for Python %s", defaultPythonVersion)
+	}
+	env, err := targetEnv(t.pythonVersion, t.platforms, t.implementation)
+	if err != nil {
+		return nil, err
+	}
+	var selected []*pkg.Requirement
+	for _, req := range n.reqs {
+		if req.Marker != nil {
+			ok, err := req.Marker.Evaluate(env)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		selected = append(selected, req)
+	}
+	f, err := newFetcher(n.client, n.cmd.dest, n.cmd.allowBinary, tags, env, n.cmd.noDeps)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.FetchLocked(ctx, n.locked); err != nil {
+		return nil, err
+	}
+	if err := f.Fetch(ctx, selected); err != nil {
+		return nil, err
 	}
-	return pkg.CreateMetadataFiles(c.dest, false)
+	return f.files, nil
 }
 
 func init() {
 	cmd := downloadCommand{
 		requirements: make([]string, 0),
+		locks:        make([]string, 0),
 	}
 	flags := flag.NewFlagSet("download", flag.ExitOnError)
 	flags.Var(&cmd.requirements, "requirements", "requirements file")
+	flags.Var(&cmd.locks, "lock", "lock file (requirements file with hashes, poetry.lock or uv.lock), implies -native")
 	flags.StringVar(&cmd.dest, "download-dir", ".", "download directory")
 	flags.StringVar(&cmd.indexUrl, "index-url", "", "index URL")
 	flags.StringVar(&cmd.proxy, "proxy", "", "proxy address in the form [user:passwd@]proxy.server:port")
@@ -92,8 +364,13 @@ func init() {
 	flags.StringVar(&cmd.pythonVersion, "python-version", "", "Python version")
 	flags.StringVar(&cmd.implementation, "implementation", "", "implementation")
 	flags.Var(&cmd.abi, "abi", "Python ABI")
+	flags.Var(&cmd.target, "target", "download target in the form `key=value[,key=value...]` with keys python-version, implementation, platform and abi, may be repeated to download for several targets")
+	flags.StringVar(&cmd.targetsFile, "targets-file", "", "TOML file describing the download targets")
 	flags.BoolVar(&cmd.noBuildIsolation, "no-build-isolation", false, "disable isolation when building")
 	flags.StringVar(&cmd.pip, "pip", "pip3", "pip executable")
+	flags.IntVar(&cmd.workers, "jobs", pkg.DefaultWorkers(), "number of packages processed concurrently")
+	flags.BoolVar(&cmd.native, "native", false, "resolve and download packages without pip")
+	flags.BoolVar(&cmd.noDeps, "no-deps", false, "don't download dependencies (native mode only)")
 	flags.Usage = func() {
 		fmt.Fprintf(flags.Output(), "Usage: %s [options] [pkgs]\n", flags.Name())
 		fmt.Fprintln(flags.Output(), "Options:")
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateMetadataFilesRemovesMismatchingFiles(t *testing.T) {
+	good := testDist{"foo", "1.0", nil}
+	tampered := testDist{"foo", "2.0", nil}
+	srv := newTestIndex(t, []testDist{good, tampered})
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, good.filename()), good.wheel(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tamperedWheel := testDist{"foo", "2.0", []string{"evil"}}.wheel(t)
+	if err := ioutil.WriteFile(filepath.Join(dir, tampered.filename()), tamperedWheel, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := &downloadCommand{dest: dir, indexUrl: srv.URL + "/simple/", workers: 1}
+	if err := c.createMetadataFiles(context.Background()); err == nil {
+		t.Fatal("expected a digest mismatch error")
+	}
+	if _, err := os.Stat(filepath.Join(dir, tampered.filename())); !os.IsNotExist(err) {
+		t.Errorf("tampered file should have been removed")
+	}
+	for _, name := range []string{good.filename(), good.filename() + ".metadata.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestNativeDownloadRequiresPythonVersionForBinaries(t *testing.T) {
+	srv := newTestIndex(t, []testDist{{"foo", "1.0", nil}})
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := &downloadCommand{dest: dir, indexUrl: srv.URL + "/simple/", workers: 1, native: true, allowBinary: true}
+	if err := c.download(context.Background(), []string{"foo"}); err == nil {
+		t.Fatal("expected an error when -allow-binary is used without -python-version")
+	}
+	c.pythonVersion = "3.8"
+	if err := c.download(context.Background(), []string{"foo"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "foo-1.0-py3-none-any.whl")); err != nil {
+		t.Error(err)
+	}
+}
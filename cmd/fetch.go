@@ -0,0 +1,364 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/montag451/go-pypi-mirror/internal/pep440"
+	"github.com/montag451/go-pypi-mirror/internal/pypi"
+	"github.com/montag451/go-pypi-mirror/pkg"
+)
+
+var implementationNames = map[string]string{
+	"cp": "cpython",
+	"pp": "pypy",
+	"ip": "ironpython",
+	"jy": "jython",
+}
+
+func platformEnv(platform string) (string, string) {
+	switch {
+	case platform == "win32":
+		return "win32", "x86"
+	case strings.HasPrefix(platform, "win_"):
+		return "win32", strings.ToUpper(strings.TrimPrefix(platform, "win_"))
+	case strings.HasPrefix(platform, "macosx_"):
+		components := strings.SplitN(platform, "_", 4)
+		return "darwin", components[len(components)-1]
+	case strings.HasPrefix(platform, "manylinux"), strings.HasPrefix(platform, "musllinux"), strings.HasPrefix(platform, "linux"):
+		components := strings.Split(platform, "_")
+		for i, c := range components {
+			if !strings.HasPrefix(c, "manylinux") && !strings.HasPrefix(c, "musllinux") && c != "linux" && strings.Trim(c, "0123456789") != "" {
+				return "linux", strings.Join(components[i:], "_")
+			}
+		}
+	}
+	return "linux", "x86_64"
+}
+
+const defaultPythonVersion = "3.8"
+
+func targetEnv(pythonVersion string, platforms []string, implementation string) (map[string]string, error) {
+	if pythonVersion == "" {
+		pythonVersion = defaultPythonVersion
+	}
+	major, minor, err := pkg.ParsePythonVersion(pythonVersion)
+	if err != nil {
+		return nil, err
+	}
+	name, ok := implementationNames[implementation]
+	if !ok {
+		name = "cpython"
+	}
+	platform, machine := "linux", "x86_64"
+	if len(platforms) > 0 {
+		platform, machine = platformEnv(platforms[0])
+	}
+	return markerEnv(fmt.Sprintf("%d.%d", major, minor), platform, machine, name)
+}
+
+type fetchedProject struct {
+	version string
+	core    *pkg.CoreMetadata
+	extras  map[string]bool
+}
+
+type fetcher struct {
+	client      *pypi.Client
+	dest        string
+	allowBinary bool
+	tags        pkg.TagSet
+	python      *pep440.Version
+	env         map[string]string
+	noDeps      bool
+	selected    map[string][]*fetchedProject
+	projects    map[string]*pypi.Project
+	files       []string
+}
+
+func newFetcher(client *pypi.Client, dest string, allowBinary bool, tags pkg.TagSet, env map[string]string, noDeps bool) (*fetcher, error) {
+	python, err := pep440.Parse(env["python_full_version"])
+	if err != nil {
+		return nil, err
+	}
+	f := &fetcher{
+		client:      client,
+		dest:        dest,
+		allowBinary: allowBinary,
+		tags:        tags,
+		python:      python,
+		env:         env,
+		noDeps:      noDeps,
+		selected:    make(map[string][]*fetchedProject),
+		projects:    make(map[string]*pypi.Project),
+	}
+	return f, nil
+}
+
+func (f *fetcher) acceptFile(file *pypi.File) bool {
+	if !pkg.IsSupported(file.Filename) {
+		return false
+	}
+	if file.RequiresPython != "" {
+		specs, err := pep440.ParseSpecifierSet(file.RequiresPython)
+		if err == nil && !specs.Contains(f.python, true) {
+			return false
+		}
+	}
+	if file.Kind() != pkg.KindWheel {
+		return f.tags == nil
+	}
+	if f.tags == nil {
+		return f.allowBinary
+	}
+	info, err := pkg.ParseWheelFilename(file.Filename)
+	if err != nil {
+		return false
+	}
+	return f.tags.Supports(info.Tags())
+}
+
+func isPinned(specs pep440.SpecifierSet) bool {
+	for _, s := range specs {
+		if s.Op == "==" || s.Op == "===" {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fetcher) selectFiles(project *pypi.Project, req *pkg.Requirement) (string, []*pypi.File, error) {
+	specs, err := pep440.ParseSpecifierSet(req.Specifier)
+	if err != nil {
+		return "", nil, err
+	}
+	pinned := isPinned(specs)
+	byVersion := make(map[string][]*pypi.File)
+	for _, file := range project.Files {
+		if file.Yanked && !pinned {
+			continue
+		}
+		if f.acceptFile(file) {
+			byVersion[file.Version] = append(byVersion[file.Version], file)
+		}
+	}
+	var versions []*pep440.Version
+	for raw := range byVersion {
+		v, err := pep440.Parse(raw)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Sort(sort.Reverse(pep440.Collection(versions)))
+	for _, prereleases := range []bool{false, true} {
+		for _, v := range versions {
+			if specs.Contains(v, prereleases) {
+				return v.Original(), byVersion[v.Original()], nil
+			}
+		}
+	}
+	return "", nil, fmt.Errorf("no matching distribution found for %q", req.Name+req.Specifier)
+}
+
+func (f *fetcher) download(ctx context.Context, file *pypi.File, digests map[string]bool) (*pkg.Pkg, error) {
+	path, downloaded, err := f.client.Download(ctx, file, f.dest)
+	if err != nil {
+		return nil, err
+	}
+	if len(digests) > 0 {
+		hash, err := pkg.HashFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !digests[hash] {
+			if downloaded {
+				os.Remove(path)
+			}
+			return nil, fmt.Errorf("%w: sha256 %s of %s is not in the lock file", pkg.ErrHashMismatch, hash, path)
+		}
+	}
+	if downloaded {
+		fmt.Printf("Saved %s\n", path)
+		if err := removeIfExists(path + pkg.MetadataExt); err != nil {
+			return nil, err
+		}
+	} else {
+		fmt.Printf("File was already downloaded %s\n", path)
+	}
+	p, err := pkg.New(path)
+	if err != nil {
+		return nil, err
+	}
+	f.files = append(f.files, p.Filename)
+	if _, err := os.Stat(p.MetadataPath()); errors.Is(err, os.ErrNotExist) {
+		if err := writeMetadataFile(f.client, p, file); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (f *fetcher) fetch(ctx context.Context, req *pkg.Requirement) (*fetchedProject, error) {
+	norm := req.NormName()
+	for _, selected := range f.selected[norm] {
+		if ok, err := pkg.MatchSpecifier(req.Specifier, selected.version); err == nil && ok {
+			return selected, nil
+		}
+	}
+	project, ok := f.projects[norm]
+	if !ok {
+		var err error
+		if project, err = f.client.Project(ctx, req.Name); err != nil {
+			return nil, err
+		}
+		f.projects[norm] = project
+	}
+	version, files, err := f.selectFiles(project, req)
+	if err != nil {
+		return nil, err
+	}
+	selected := &fetchedProject{version: version, extras: make(map[string]bool)}
+	for _, file := range files {
+		p, err := f.download(ctx, file, nil)
+		if err != nil {
+			return nil, err
+		}
+		if selected.core != nil && p.Kind != pkg.KindWheel {
+			continue
+		}
+		core, err := p.ReadCoreMetadata()
+		if err != nil {
+			log.Printf("%s: %v", p.Filename, err)
+			continue
+		}
+		selected.core = core
+	}
+	f.selected[norm] = append(f.selected[norm], selected)
+	return selected, nil
+}
+
+func (f *fetcher) Fetch(ctx context.Context, reqs []*pkg.Requirement) error {
+	queue := append([]*pkg.Requirement(nil), reqs...)
+	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		req := queue[0]
+		queue = queue[1:]
+		if req.URL != "" {
+			return fmt.Errorf("unsupported direct reference %q", req.Name+" @ "+req.URL)
+		}
+		selected, err := f.fetch(ctx, req)
+		if err != nil {
+			return err
+		}
+		if f.noDeps || selected.core == nil {
+			continue
+		}
+		extras := []string{""}
+		for _, extra := range req.Extras {
+			extras = append(extras, pkg.Normalize(extra))
+		}
+		for _, extra := range extras {
+			if selected.extras[extra] {
+				continue
+			}
+			selected.extras[extra] = true
+			f.env["extra"] = extra
+			for _, rawReq := range selected.core.RequiresDist {
+				dep, err := pkg.ParseRequirement(rawReq)
+				if err != nil {
+					log.Printf("%s %s: %v", req.Name, selected.version, err)
+					continue
+				}
+				if dep.Marker != nil {
+					ok, err := dep.Marker.Evaluate(f.env)
+					if err != nil {
+						log.Printf("%s %s: failed to evaluate marker of %q: %v", req.Name, selected.version, rawReq, err)
+						continue
+					}
+					if !ok {
+						continue
+					}
+				}
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return nil
+}
+
+func sameVersion(a string, b *pep440.Version) bool {
+	v, err := pep440.Parse(a)
+	if err != nil {
+		return a == b.Original()
+	}
+	return v.Equal(b)
+}
+
+func (f *fetcher) FetchLocked(ctx context.Context, locked []*pkg.LockedPackage) error {
+	for _, l := range locked {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if l.Marker != nil {
+			f.env["extra"] = ""
+			ok, err := l.Marker.Evaluate(f.env)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+		}
+		version, err := pep440.Parse(l.Version)
+		if err != nil {
+			return fmt.Errorf("invalid version %q for %s: %w", l.Version, l.Name, err)
+		}
+		project, err := f.client.Project(ctx, l.Name)
+		if err != nil {
+			return err
+		}
+		digests := l.Digests()["sha256"]
+		filenames := make(map[string]bool, len(l.Files))
+		for _, filename := range l.Files {
+			filenames[filename] = true
+		}
+		var files []*pypi.File
+		for _, file := range project.Files {
+			if !sameVersion(file.Version, version) {
+				continue
+			}
+			if f.tags != nil && !f.acceptFile(file) {
+				continue
+			}
+			var ok bool
+			switch upstream := strings.ToLower(file.Hashes["sha256"]); {
+			case len(digests) > 0 && upstream != "":
+				ok = digests[upstream]
+			case len(filenames) > 0:
+				ok = filenames[file.Filename]
+			default:
+				ok = f.acceptFile(file)
+			}
+			if ok {
+				files = append(files, file)
+			}
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no distribution of %s %s matches the lock file", l.Name, l.Version)
+		}
+		for _, file := range files {
+			if _, err := f.download(ctx, file, digests); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/montag451/go-pypi-mirror/internal/pypi"
+	"github.com/montag451/go-pypi-mirror/pkg"
+)
+
+type testDist struct {
+	name         string
+	version      string
+	requiresDist []string
+}
+
+func (d testDist) filename() string {
+	return d.name + "-" + d.version + "-py3-none-any.whl"
+}
+
+func (d testDist) wheel(t *testing.T) []byte {
+	t.Helper()
+	var metadata strings.Builder
+	fmt.Fprintf(&metadata, "Metadata-Version: 2.1\nName: %s\nVersion: %s\n", d.name, d.version)
+	for _, req := range d.requiresDist {
+		fmt.Fprintf(&metadata, "Requires-Dist: %s\n", req)
+	}
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create(d.name + "-" + d.version + ".dist-info/METADATA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte(metadata.String())); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newTestIndex(t *testing.T, dists []testDist) *httptest.Server {
+	t.Helper()
+	type file struct {
+		Filename string            `json:"filename"`
+		URL      string            `json:"url"`
+		Hashes   map[string]string `json:"hashes"`
+	}
+	projects := make(map[string][]file)
+	content := make(map[string][]byte)
+	for _, d := range dists {
+		data := d.wheel(t)
+		content[d.filename()] = data
+		norm := pkg.Normalize(d.name)
+		projects[norm] = append(projects[norm], file{
+			Filename: d.filename(),
+			URL:      "/files/" + d.filename(),
+			Hashes:   map[string]string{"sha256": fmt.Sprintf("%x", sha256.Sum256(data))},
+		})
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/simple/"):
+			files, ok := projects[strings.Trim(strings.TrimPrefix(r.URL.Path, "/simple/"), "/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "application/vnd.pypi.simple.v1+json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"files": files})
+		case strings.HasPrefix(r.URL.Path, "/files/"):
+			data, ok := content[strings.TrimPrefix(r.URL.Path, "/files/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			http.ServeContent(w, r, r.URL.Path, time.Time{}, bytes.NewReader(data))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestFetcherResolvesDependencies(t *testing.T) {
+	srv := newTestIndex(t, []testDist{
+		{"app", "1.0", []string{
+			"lib>=1.0",
+			"extra-dep; extra == 'x'",
+			"win-only; sys_platform == 'win32'",
+		}},
+		{"app", "0.9", nil},
+		{"lib", "1.0", nil},
+		{"lib", "2.0", []string{"leaf"}},
+		{"lib", "3.0a1", nil},
+		{"leaf", "1.0", nil},
+		{"extra_dep", "1.0", nil},
+		{"win_only", "1.0", nil},
+	})
+	defer srv.Close()
+	tests := []struct {
+		reqs   []string
+		noDeps bool
+		want   []string
+	}{
+		{
+			reqs: []string{"app"},
+			want: []string{"app-1.0-py3-none-any.whl", "leaf-1.0-py3-none-any.whl", "lib-2.0-py3-none-any.whl"},
+		},
+		{
+			reqs: []string{"app[x]", "lib<2"},
+			want: []string{"app-1.0-py3-none-any.whl", "extra_dep-1.0-py3-none-any.whl", "lib-1.0-py3-none-any.whl"},
+		},
+		{
+			reqs:   []string{"app"},
+			noDeps: true,
+			want:   []string{"app-1.0-py3-none-any.whl"},
+		},
+		{
+			reqs: []string{"app==0.9", "lib>=3.0a1"},
+			want: []string{"app-0.9-py3-none-any.whl", "lib-3.0a1-py3-none-any.whl"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.reqs, ","), func(t *testing.T) {
+			client, err := pypi.NewClient(srv.URL+"/simple/", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir, err := ioutil.TempDir("", "fetch")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			env, err := targetEnv("3.8", nil, "cp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := newFetcher(client, dir, true, nil, env, test.noDeps)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var reqs []*pkg.Requirement
+			for _, raw := range test.reqs {
+				req, err := pkg.ParseRequirement(raw)
+				if err != nil {
+					t.Fatal(err)
+				}
+				reqs = append(reqs, req)
+			}
+			if err := f.Fetch(context.Background(), reqs); err != nil {
+				t.Fatal(err)
+			}
+			got := append([]string(nil), f.files...)
+			sort.Strings(got)
+			if strings.Join(got, " ") != strings.Join(test.want, " ") {
+				t.Errorf("fetched %v, want %v", got, test.want)
+			}
+			for _, filename := range got {
+				if _, err := os.Stat(filepath.Join(dir, filename+pkg.MetadataExt)); err != nil {
+					t.Errorf("missing sidecar of %s: %v", filename, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	generationsDir   = "generations"
+	currentLink      = "current"
+	stagingPrefix    = ".staging-"
+	generationLayout = "20060102T150405.000000000Z"
+)
+
+func listGenerations(mirrorDir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(mirrorDir, generationsDir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var generations []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			generations = append(generations, e.Name())
+		}
+	}
+	sort.Strings(generations)
+	return generations, nil
+}
+
+func currentGeneration(mirrorDir string) (string, error) {
+	target, err := os.Readlink(filepath.Join(mirrorDir, currentLink))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	return filepath.Base(target), nil
+}
+
+func cloneTree(src, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			if err := os.Link(path, target); err != nil {
+				return copyFile(target, path)
+			}
+			return nil
+		}
+	})
+}
+
+func publishGeneration(mirrorDir, generation string) error {
+	tmp := filepath.Join(mirrorDir, "."+currentLink+".tmp")
+	if err := removeIfExists(tmp); err != nil {
+		return err
+	}
+	if err := os.Symlink(filepath.Join(generationsDir, generation), tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(mirrorDir, currentLink))
+}
+
+func indexOf(generations []string, generation string) int {
+	idx := sort.SearchStrings(generations, generation)
+	if idx == len(generations) || generations[idx] != generation {
+		return -1
+	}
+	return idx
+}
+
+func checkGeneration(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("generation %s has a dangling link %s: %w", filepath.Base(dir), path, err)
+		}
+		return nil
+	})
+}
+
+func pruneGenerations(mirrorDir string, keep int) error {
+	generations, err := listGenerations(mirrorDir)
+	if err != nil {
+		return err
+	}
+	current, err := currentGeneration(mirrorDir)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(generations)-keep; i++ {
+		if generations[i] == current {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(mirrorDir, generationsDir, generations[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeStagingDirs(root string) error {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), stagingPrefix) {
+			if err := os.RemoveAll(filepath.Join(root, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *createCommand) buildGeneration(ctx context.Context, mirrorDir string, build func(string) error) (err error) {
+	root := filepath.Join(mirrorDir, generationsDir)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+	if err := removeStagingDirs(root); err != nil {
+		return err
+	}
+	generation := time.Now().UTC().Format(generationLayout)
+	staging := filepath.Join(root, stagingPrefix+generation)
+	defer func() {
+		if err != nil {
+			os.RemoveAll(staging)
+		}
+	}()
+	current, err := currentGeneration(mirrorDir)
+	if err != nil {
+		return err
+	}
+	if current != "" {
+		if err := cloneTree(filepath.Join(root, current), staging); err != nil {
+			return fmt.Errorf("failed to clone generation %s: %w", current, err)
+		}
+	}
+	if err := build(staging); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.Rename(staging, filepath.Join(root, generation)); err != nil {
+		return err
+	}
+	if err := publishGeneration(mirrorDir, generation); err != nil {
+		return err
+	}
+	return pruneGenerations(mirrorDir, c.generations)
+}
+
+type rollbackCommand struct {
+	flags     *flag.FlagSet
+	mirrorDir string
+	to        string
+}
+
+func (c *rollbackCommand) FlagSet() *flag.FlagSet {
+	return c.flags
+}
+
+func (c *rollbackCommand) Execute(context.Context) error {
+	generations, err := listGenerations(c.mirrorDir)
+	if err != nil {
+		return err
+	}
+	current, err := currentGeneration(c.mirrorDir)
+	if err != nil {
+		return err
+	}
+	target := c.to
+	if target == "" {
+		if current == "" {
+			return errors.New("no current generation to roll back from")
+		}
+		idx := indexOf(generations, current)
+		if idx == -1 {
+			return fmt.Errorf("current generation %q not found in %s", current, filepath.Join(c.mirrorDir, generationsDir))
+		}
+		if idx == 0 {
+			return errors.New("no previous generation to roll back to")
+		}
+		target = generations[idx-1]
+	} else if indexOf(generations, target) == -1 {
+		return fmt.Errorf("unknown generation %q", target)
+	}
+	if err := checkGeneration(filepath.Join(c.mirrorDir, generationsDir, target)); err != nil {
+		return err
+	}
+	if err := publishGeneration(c.mirrorDir, target); err != nil {
+		return err
+	}
+	fmt.Printf("%s now points to %s\n", filepath.Join(c.mirrorDir, currentLink), target)
+	return nil
+}
+
+func init() {
+	cmd := rollbackCommand{}
+	flags := flag.NewFlagSet("rollback", flag.ExitOnError)
+	flags.StringVar(&cmd.mirrorDir, "mirror-dir", ".", "mirror dir")
+	flags.StringVar(&cmd.to, "to", "", "`generation` to roll back to (default to the one preceding the current one)")
+	cmd.flags = flags
+	RegisterCommand(&cmd)
+}
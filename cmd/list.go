@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/montag451/go-pypi-mirror/pkg"
 )
@@ -17,17 +18,33 @@ type listCommand struct {
 	name        string
 	json        bool
 	useNormName bool
+	files       bool
+	kind        string
+	workers     int
 }
 
 func (c *listCommand) FlagSet() *flag.FlagSet {
 	return c.flags
 }
 
-func (c *listCommand) Execute(context.Context) error {
-	pkgs, err := pkg.List(c.downloadDir, true)
+func (c *listCommand) Execute(ctx context.Context) error {
+	pkgs, err := pkg.List(ctx, c.downloadDir, true, c.workers)
 	if err != nil {
 		return err
 	}
+	if c.kind != "" {
+		kind := pkg.Kind(c.kind)
+		if kind != pkg.KindSdist && kind != pkg.KindWheel {
+			return fmt.Errorf("unknown kind %q", c.kind)
+		}
+		filtered := pkgs[:0]
+		for _, p := range pkgs {
+			if p.Kind == kind {
+				filtered = append(filtered, p)
+			}
+		}
+		pkgs = filtered
+	}
 	groups := pkg.GroupByName(pkgs)
 	pkgsByName := make([]map[string]interface{}, 0, len(groups))
 	for _, group := range groups {
@@ -40,13 +57,31 @@ func (c *listCommand) Execute(context.Context) error {
 		}
 		groups := pkg.GroupByVersion(group.Pkgs)
 		versions := make([]string, len(groups))
+		files := make([]map[string]interface{}, 0, len(group.Pkgs))
 		for i := len(groups) - 1; i >= 0; i-- {
 			versions[i] = groups[i].Key.(string)
 		}
-		pkgsByName = append(pkgsByName, map[string]interface{}{
+		for _, group := range groups {
+			for _, p := range group.Pkgs {
+				files = append(files, map[string]interface{}{
+					"filename":      p.Filename,
+					"version":       p.Metadata.Version,
+					"kind":          p.Kind,
+					"build_tag":     p.Metadata.BuildTag,
+					"python_tags":   p.Metadata.PythonTags,
+					"abi_tags":      p.Metadata.ABITags,
+					"platform_tags": p.Metadata.PlatformTags,
+				})
+			}
+		}
+		entry := map[string]interface{}{
 			"name":     name,
 			"versions": versions,
-		})
+		}
+		if c.files {
+			entry["files"] = files
+		}
+		pkgsByName = append(pkgsByName, entry)
 	}
 	if c.json {
 		return json.NewEncoder(os.Stdout).Encode(pkgsByName)
@@ -58,6 +93,25 @@ func (c *listCommand) Execute(context.Context) error {
 		}
 		for _, v := range pkg["versions"].([]string) {
 			fmt.Printf("  %s\n", v)
+			if !c.files {
+				continue
+			}
+			for _, f := range pkg["files"].([]map[string]interface{}) {
+				if f["version"] != v {
+					continue
+				}
+				fmt.Printf("    %s (%s", f["filename"], f["kind"])
+				if tags := f["python_tags"].([]string); len(tags) > 0 {
+					fmt.Printf(": %s-%s-%s",
+						strings.Join(tags, "."),
+						strings.Join(f["abi_tags"].([]string), "."),
+						strings.Join(f["platform_tags"].([]string), "."))
+				}
+				if build := f["build_tag"].(string); build != "" {
+					fmt.Printf(", build %s", build)
+				}
+				fmt.Println(")")
+			}
 		}
 	}
 	return nil
@@ -71,6 +125,9 @@ func init() {
 	flags.StringVar(&cmd.name, "name", "", "list only the versions of `name`")
 	flags.BoolVar(&cmd.json, "json", false, "JSON output")
 	flags.BoolVar(&cmd.useNormName, "use-norm-name", false, "use the normalized name instead of the regular name")
+	flags.BoolVar(&cmd.files, "files", false, "list the files of each version along with their kind and tags")
+	flags.StringVar(&cmd.kind, "kind", "", "list only the files of `kind` (sdist or wheel)")
+	flags.IntVar(&cmd.workers, "jobs", pkg.DefaultWorkers(), "number of packages processed concurrently")
 	cmd.flags = flags
 	RegisterCommand(&cmd)
 }
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/montag451/go-pypi-mirror/internal/fsutil"
+	"github.com/montag451/go-pypi-mirror/pkg"
+)
+
+const manifestFilename = ".manifest.json"
+
+type manifestFile struct {
+	Filename         string `json:"filename"`
+	Hash             string `json:"sha256"`
+	RequiresPython   string `json:"requires_python,omitempty"`
+	CoreMetadataHash string `json:"core_metadata_sha256,omitempty"`
+	UploadTime       string `json:"upload_time,omitempty"`
+}
+
+type manifestProject struct {
+	Name  string         `json:"name"`
+	Files []manifestFile `json:"files"`
+}
+
+func newManifestProject(pkgs []*pkg.Pkg) *manifestProject {
+	project := &manifestProject{
+		Name:  pkgs[0].Metadata.Name,
+		Files: make([]manifestFile, 0, len(pkgs)),
+	}
+	for _, p := range pkgs {
+		file := manifestFile{
+			Filename:         p.Filename,
+			Hash:             p.Metadata.Hash,
+			RequiresPython:   p.Metadata.RequiresPython,
+			CoreMetadataHash: p.Metadata.CoreMetadataHash,
+		}
+		if p.Metadata.Source != nil {
+			file.UploadTime = p.Metadata.Source.UploadTime
+		}
+		project.Files = append(project.Files, file)
+	}
+	sort.Slice(project.Files, func(i, j int) bool {
+		return project.Files[i].Filename < project.Files[j].Filename
+	})
+	return project
+}
+
+func (p *manifestProject) file(filename string) (manifestFile, bool) {
+	i := sort.Search(len(p.Files), func(i int) bool {
+		return p.Files[i].Filename >= filename
+	})
+	if i < len(p.Files) && p.Files[i].Filename == filename {
+		return p.Files[i], true
+	}
+	return manifestFile{}, false
+}
+
+func (p *manifestProject) equal(o *manifestProject) bool {
+	if p == nil || o == nil {
+		return p == o
+	}
+	if p.Name != o.Name || len(p.Files) != len(o.Files) {
+		return false
+	}
+	for i := range p.Files {
+		if p.Files[i] != o.Files[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type manifest struct {
+	Copy     bool                        `json:"copy"`
+	HardLink bool                        `json:"hard_link,omitempty"`
+	Projects map[string]*manifestProject `json:"projects"`
+}
+
+func loadManifest(path string) (*manifest, error) {
+	m := &manifest{Projects: make(map[string]*manifestProject)}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return m, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(m); err != nil {
+		return nil, err
+	}
+	if m.Projects == nil {
+		m.Projects = make(map[string]*manifestProject)
+	}
+	return m, nil
+}
+
+func (m *manifest) save(path string) error {
+	return fsutil.WriteFile(path, 0644, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(m)
+	})
+}
@@ -11,14 +11,15 @@ type writeMetadataCommand struct {
 	flags       *flag.FlagSet
 	downloadDir string
 	overwrite   bool
+	workers     int
 }
 
 func (c *writeMetadataCommand) FlagSet() *flag.FlagSet {
 	return c.flags
 }
 
-func (c *writeMetadataCommand) Execute(context.Context) error {
-	return pkg.CreateMetadataFiles(c.downloadDir, c.overwrite)
+func (c *writeMetadataCommand) Execute(ctx context.Context) error {
+	return pkg.CreateMetadataFiles(ctx, c.downloadDir, c.overwrite, c.workers)
 }
 
 func init() {
@@ -26,6 +27,7 @@ func init() {
 	flags := flag.NewFlagSet("write-metadata", flag.ExitOnError)
 	flags.StringVar(&cmd.downloadDir, "download-dir", "", "download dir")
 	flags.BoolVar(&cmd.overwrite, "overwrite", false, "overwrite metadata files")
+	flags.IntVar(&cmd.workers, "jobs", pkg.DefaultWorkers(), "number of packages processed concurrently")
 	cmd.flags = flags
 	RegisterCommand(&cmd)
 }
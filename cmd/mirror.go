@@ -2,14 +2,17 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"html/template"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 
+	"github.com/montag451/go-pypi-mirror/internal/fsutil"
 	"github.com/montag451/go-pypi-mirror/pkg"
 )
 
@@ -37,7 +40,9 @@ var (
   <body>
     <h1>Links for {{ $firstPkg.Metadata.Name }}</h1>
     {{- range . }}
-    <a href="{{ .Filename }}#sha256={{ .Metadata.Hash }}">{{ .Filename }}</a><br/>
+    <a href="{{ .Filename }}#sha256={{ .Metadata.Hash }}"
+      {{- with .Metadata.RequiresPython }} data-requires-python="{{ . }}"{{ end }}
+      {{- with .Metadata.CoreMetadataHash }} data-core-metadata="sha256={{ . }}" data-dist-info-metadata="sha256={{ . }}"{{ end }}>{{ .Filename }}</a><br/>
     {{- end }}
   </body>
 </html>
@@ -52,18 +57,249 @@ func generatePackageHTML(w io.Writer, pkgs []*pkg.Pkg) error {
 	return packageHTMLTemplate.Execute(w, pkgs)
 }
 
+const (
+	simpleAPIVersion      = "1.1"
+	simpleJSONContentType = "application/vnd.pypi.simple.v1+json"
+	simpleHTMLContentType = "application/vnd.pypi.simple.v1+html"
+)
+
+type simpleMeta struct {
+	APIVersion string `json:"api-version"`
+}
+
+type simpleProjectRef struct {
+	Name string `json:"name"`
+}
+
+type simpleRoot struct {
+	Meta     simpleMeta         `json:"meta"`
+	Projects []simpleProjectRef `json:"projects"`
+}
+
+type simpleFile struct {
+	Filename         string            `json:"filename"`
+	URL              string            `json:"url"`
+	Hashes           map[string]string `json:"hashes"`
+	RequiresPython   string            `json:"requires-python,omitempty"`
+	CoreMetadata     map[string]string `json:"core-metadata,omitempty"`
+	DistInfoMetadata map[string]string `json:"dist-info-metadata,omitempty"`
+	Size             int64             `json:"size"`
+	UploadTime       string            `json:"upload-time,omitempty"`
+	Yanked           bool              `json:"yanked"`
+}
+
+type simpleProject struct {
+	Meta     simpleMeta   `json:"meta"`
+	Name     string       `json:"name"`
+	Versions []string     `json:"versions"`
+	Files    []simpleFile `json:"files"`
+}
+
+func generateRootJSON(w io.Writer, pkgs []*pkg.Pkg) error {
+	root := simpleRoot{
+		Meta:     simpleMeta{simpleAPIVersion},
+		Projects: make([]simpleProjectRef, 0, len(pkgs)),
+	}
+	for _, p := range pkgs {
+		root.Projects = append(root.Projects, simpleProjectRef{p.Metadata.Name})
+	}
+	return json.NewEncoder(w).Encode(root)
+}
+
+func generatePackageJSON(w io.Writer, pkgs []*pkg.Pkg) error {
+	project := simpleProject{
+		Meta:  simpleMeta{simpleAPIVersion},
+		Name:  pkgs[0].Metadata.NormName,
+		Files: make([]simpleFile, 0, len(pkgs)),
+	}
+	for _, group := range pkg.GroupByVersion(append([]*pkg.Pkg(nil), pkgs...)) {
+		project.Versions = append(project.Versions, group.Key.(string))
+	}
+	for _, p := range pkgs {
+		info, err := os.Stat(p.Path)
+		if err != nil {
+			return err
+		}
+		file := simpleFile{
+			Filename:       p.Filename,
+			URL:            p.Filename,
+			Hashes:         map[string]string{"sha256": p.Metadata.Hash},
+			RequiresPython: p.Metadata.RequiresPython,
+			Size:           info.Size(),
+		}
+		if p.Metadata.Source != nil {
+			file.UploadTime = p.Metadata.Source.UploadTime
+		}
+		if h := p.Metadata.CoreMetadataHash; h != "" {
+			file.CoreMetadata = map[string]string{"sha256": h}
+			file.DistInfoMetadata = file.CoreMetadata
+		}
+		project.Files = append(project.Files, file)
+	}
+	return json.NewEncoder(w).Encode(project)
+}
+
+func writeIndexFile(path string, generate func(io.Writer, []*pkg.Pkg) error, pkgs []*pkg.Pkg) error {
+	return fsutil.WriteFile(path, 0644, func(w io.Writer) error {
+		return generate(w, pkgs)
+	})
+}
+
+func writeCoreMetadata(path string, p *pkg.Pkg) error {
+	data, err := p.CoreMetadata()
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(path, 0644, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+func updateCoreMetadata(dest string, p *pkg.Pkg) error {
+	if err := removeIfExists(dest + ".metadata"); err != nil {
+		return err
+	}
+	if p.Metadata.CoreMetadataHash == "" {
+		return nil
+	}
+	if err := writeCoreMetadata(dest+".metadata", p); err != nil {
+		return fmt.Errorf("failed to write core metadata of %s: %w", p.Path, err)
+	}
+	return nil
+}
+
+func copyFile(destPath, srcPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return fsutil.WriteFile(destPath, 0644, func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+}
+
+func linkFile(destPath, srcPath string) error {
+	if err := os.Link(srcPath, destPath); err != nil {
+		return copyFile(destPath, srcPath)
+	}
+	return nil
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
 type createCommand struct {
 	flags       *flag.FlagSet
 	downloadDir string
 	mirrorDir   string
 	copy        bool
+	force       bool
+	generations int
+	workers     int
 }
 
 func (c *createCommand) FlagSet() *flag.FlagSet {
 	return c.flags
 }
 
-func (c *createCommand) Execute(context.Context) error {
+func (c *createCommand) hardLink() bool {
+	return !c.copy && c.generations > 0
+}
+
+func (c *createCommand) writeProject(dir string, pkgs []*pkg.Pkg, old *manifestProject) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		dest := filepath.Join(dir, pkg.Filename)
+		if f, ok := old.file(pkg.Filename); ok && f.Hash == pkg.Metadata.Hash && fileExists(dest) {
+			if f.CoreMetadataHash == pkg.Metadata.CoreMetadataHash && (f.CoreMetadataHash == "" || fileExists(dest+".metadata")) {
+				continue
+			}
+			if err := updateCoreMetadata(dest, pkg); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := removeIfExists(dest); err != nil {
+			return err
+		}
+		switch {
+		case c.copy:
+			if err := copyFile(dest, pkg.Path); err != nil {
+				return fmt.Errorf("failed to copy %s to %s: %w", pkg.Path, dest, err)
+			}
+		case c.hardLink():
+			if err := linkFile(dest, pkg.Path); err != nil {
+				return fmt.Errorf("failed to link %s to %s: %w", pkg.Path, dest, err)
+			}
+		default:
+			link, err := filepath.Rel(dir, pkg.Path)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, dest); err != nil {
+				return err
+			}
+		}
+		if err := updateCoreMetadata(dest, pkg); err != nil {
+			return err
+		}
+	}
+	current := newManifestProject(pkgs)
+	for _, f := range old.Files {
+		if _, ok := current.file(f.Filename); ok {
+			continue
+		}
+		dest := filepath.Join(dir, f.Filename)
+		if err := removeIfExists(dest); err != nil {
+			return err
+		}
+		if err := removeIfExists(dest + ".metadata"); err != nil {
+			return err
+		}
+	}
+	if err := writeIndexFile(filepath.Join(dir, "index.html"), generatePackageHTML, pkgs); err != nil {
+		return err
+	}
+	return writeIndexFile(filepath.Join(dir, "index.json"), generatePackageJSON, pkgs)
+}
+
+func removeProject(dir string, old *manifestProject) error {
+	for _, f := range old.Files {
+		dest := filepath.Join(dir, f.Filename)
+		if err := removeIfExists(dest); err != nil {
+			return err
+		}
+		if err := removeIfExists(dest + ".metadata"); err != nil {
+			return err
+		}
+	}
+	for _, name := range []string{"index.html", "index.json"} {
+		if err := removeIfExists(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("unable to remove %s: %v", dir, err)
+	}
+	return nil
+}
+
+func (c *createCommand) Execute(ctx context.Context) error {
 	downloadDir, err := filepath.Abs(c.downloadDir)
 	if err != nil {
 		return err
@@ -72,77 +308,91 @@ func (c *createCommand) Execute(context.Context) error {
 	if err != nil {
 		return err
 	}
-	pkgs, err := pkg.List(downloadDir, false)
+	pkgs, err := pkg.List(ctx, downloadDir, false, c.workers)
 	if err != nil {
 		return err
 	}
+	if err := os.MkdirAll(mirrorDir, 0755); err != nil {
+		return err
+	}
+	if c.generations > 0 {
+		return c.buildGeneration(ctx, mirrorDir, func(dir string) error {
+			return c.build(ctx, pkgs, dir)
+		})
+	}
+	return c.build(ctx, pkgs, mirrorDir)
+}
+
+func (c *createCommand) build(ctx context.Context, pkgs []*pkg.Pkg, mirrorDir string) error {
+	if err := os.MkdirAll(mirrorDir, 0755); err != nil {
+		return err
+	}
+	manifestPath := filepath.Join(mirrorDir, manifestFilename)
+	oldManifest, err := loadManifest(manifestPath)
+	if err != nil {
+		return fmt.Errorf("failed to load manifest %s: %w", manifestPath, err)
+	}
+	if c.force || oldManifest.Copy != c.copy || oldManifest.HardLink != c.hardLink() {
+		oldManifest.Projects = make(map[string]*manifestProject)
+	}
+	newManifest := &manifest{
+		Copy:     c.copy,
+		HardLink: c.hardLink(),
+		Projects: make(map[string]*manifestProject),
+	}
 	groups := pkg.GroupByNormName(pkgs)
 	rootPkgs := make([]*pkg.Pkg, 0, len(groups))
+	rootChanged := len(groups) != len(oldManifest.Projects)
 	for _, group := range groups {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		normName := group.Key.(string)
 		pkgs := group.Pkgs
 		dir := filepath.Join(mirrorDir, normName)
-		err := os.MkdirAll(dir, 0755)
-		if err != nil {
-			return err
-		}
 		pkg.FixNames(pkgs)
-		copyFile := func(destPath, srcPath string) (err error) {
-			src, err := os.Open(srcPath)
-			if err != nil {
-				return
-			}
-			defer src.Close()
-			dest, err := os.Create(destPath)
-			if err != nil {
-				return
-			}
-			defer func() {
-				cerr := dest.Close()
-				if cerr != nil {
-					err = cerr
-				}
-			}()
-			_, err = io.Copy(dest, src)
-			return
-		}
-		for _, pkg := range pkgs {
-			dest := filepath.Join(dir, pkg.Filename)
-			if c.copy {
-				if err := copyFile(dest, pkg.Path); err != nil {
-					return fmt.Errorf("failed to copy %s to %s: %w", pkg.Path, dest, err)
-				}
-			} else {
-				link, err := filepath.Rel(dir, pkg.Path)
-				if err != nil {
-					return err
-				}
-				err = os.Symlink(link, dest)
-				if err != nil && !errors.Is(err, os.ErrExist) {
-					return err
-				}
-			}
+		rootPkgs = append(rootPkgs, pkgs[0])
+		current := newManifestProject(pkgs)
+		newManifest.Projects[normName] = current
+		old, ok := oldManifest.Projects[normName]
+		if !ok || old.Name != current.Name {
+			rootChanged = true
 		}
-		f, err := os.Create(filepath.Join(dir, "index.html"))
-		if err != nil {
+		if current.equal(old) && fileExists(filepath.Join(dir, "index.html")) && fileExists(filepath.Join(dir, "index.json")) {
+			continue
+		}
+		if old == nil {
+			old = &manifestProject{}
+		}
+		if err := c.writeProject(dir, pkgs, old); err != nil {
 			return err
 		}
-		err = generatePackageHTML(f, pkgs)
-		f.Close()
-		if err != nil {
+	}
+	for normName, old := range oldManifest.Projects {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, ok := newManifest.Projects[normName]; ok {
+			continue
+		}
+		if err := removeProject(filepath.Join(mirrorDir, normName), old); err != nil {
 			return err
 		}
-		rootPkgs = append(rootPkgs, pkgs[0])
 	}
-	if len(rootPkgs) > 0 {
-		f, err := os.Create(filepath.Join(mirrorDir, "index.html"))
-		if err != nil {
+	rootIndexes := map[string]func(io.Writer, []*pkg.Pkg) error{
+		"index.html": generateRootHTML,
+		"index.json": generateRootJSON,
+	}
+	for name, generate := range rootIndexes {
+		path := filepath.Join(mirrorDir, name)
+		if !rootChanged && fileExists(path) {
+			continue
+		}
+		if err := writeIndexFile(path, generate, rootPkgs); err != nil {
 			return err
 		}
-		defer f.Close()
-		return generateRootHTML(f, rootPkgs)
 	}
-	return nil
+	return newManifest.save(manifestPath)
 }
 
 func init() {
@@ -151,6 +401,9 @@ func init() {
 	flags.StringVar(&cmd.downloadDir, "download-dir", ".", "download dir")
 	flags.StringVar(&cmd.mirrorDir, "mirror-dir", ".", "mirror dir")
 	flags.BoolVar(&cmd.copy, "copy", false, "copy instead of symlinking packages")
+	flags.BoolVar(&cmd.force, "force", false, "regenerate every project instead of only the ones that changed")
+	flags.IntVar(&cmd.generations, "generations", 0, "build the mirror in a new generation published atomically through the current symlink, keeping the last `N` generations, packages are hard-linked unless -copy is given (0 to update the mirror dir in place)")
+	flags.IntVar(&cmd.workers, "jobs", pkg.DefaultWorkers(), "number of packages processed concurrently")
 	cmd.flags = flags
 	RegisterCommand(&cmd)
 }
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/montag451/go-pypi-mirror/pkg"
+)
+
+func TestCreateBackfillsCoreMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	downloadDir := filepath.Join(dir, "download")
+	mirrorDir := filepath.Join(dir, "mirror")
+	if err := os.Mkdir(downloadDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	dist := testDist{"foo", "1.0", nil}
+	path := filepath.Join(downloadDir, dist.filename())
+	if err := ioutil.WriteFile(path, dist.wheel(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := pkg.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coreMetadataHash := p.Metadata.CoreMetadataHash
+	if coreMetadataHash == "" {
+		t.Fatal("missing core metadata hash")
+	}
+	c := &createCommand{}
+	p.Metadata.CoreMetadataHash = ""
+	if err := c.build(context.Background(), []*pkg.Pkg{p}, mirrorDir); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(mirrorDir, "foo", dist.filename())
+	if fileExists(dest + ".metadata") {
+		t.Fatalf("unexpected core metadata file without core metadata hash")
+	}
+	p.Metadata.CoreMetadataHash = coreMetadataHash
+	if err := c.build(context.Background(), []*pkg.Pkg{p}, mirrorDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dest + ".metadata"); err != nil {
+		t.Errorf("core metadata file not written: %v", err)
+	}
+	index, err := ioutil.ReadFile(filepath.Join(mirrorDir, "foo", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), `data-core-metadata="sha256=`+coreMetadataHash+`"`) {
+		t.Errorf("index does not advertise the core metadata:\n%s", index)
+	}
+}
+
+func TestBuildGenerationRemovesStaleStagingDirs(t *testing.T) {
+	mirrorDir, err := ioutil.TempDir("", "mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(mirrorDir)
+	stale := filepath.Join(mirrorDir, generationsDir, stagingPrefix+"20200101T000000.000000000Z")
+	if err := os.MkdirAll(filepath.Join(stale, "foo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	c := &createCommand{generations: 2}
+	err = c.buildGeneration(context.Background(), mirrorDir, func(dir string) error {
+		return os.MkdirAll(dir, 0755)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale staging dir %s not removed", stale)
+	}
+	generations, err := listGenerations(mirrorDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generations) != 1 {
+		t.Errorf("got generations %v, want exactly one", generations)
+	}
+}
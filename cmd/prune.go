@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/montag451/go-pypi-mirror/internal/pep440"
+	"github.com/montag451/go-pypi-mirror/pkg"
+)
+
+type pruneCommand struct {
+	flags           *flag.FlagSet
+	downloadDir     string
+	keepLatest      uint
+	keep            string
+	dropPrereleases bool
+	dryRun          bool
+	workers         int
+}
+
+func (c *pruneCommand) FlagSet() *flag.FlagSet {
+	return c.flags
+}
+
+func (c *pruneCommand) Execute(ctx context.Context) error {
+	if c.keepLatest == 0 && c.keep == "" && !c.dropPrereleases {
+		return errors.New("no retention policy specified")
+	}
+	keep, err := pep440.ParseSpecifierSet(c.keep)
+	if err != nil {
+		return fmt.Errorf("invalid version constraint %q: %w", c.keep, err)
+	}
+	pkgs, err := pkg.List(ctx, c.downloadDir, true, c.workers)
+	if err != nil {
+		return err
+	}
+	for _, project := range pkg.GroupByNormName(pkgs) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		groups := pkg.GroupByVersion(project.Pkgs)
+		var retained uint
+		for i := len(groups) - 1; i >= 0; i-- {
+			rawVersion := groups[i].Key.(string)
+			v, err := pep440.Parse(rawVersion)
+			if err != nil {
+				log.Printf("keeping %s %s: %v", project.Key, rawVersion, err)
+				continue
+			}
+			if c.keep != "" && keep.Contains(v, true) {
+				continue
+			}
+			var remove bool
+			switch {
+			case c.dropPrereleases && v.IsPrerelease():
+				remove = true
+			case c.keepLatest > 0:
+				remove = retained >= c.keepLatest
+				retained++
+			default:
+				remove = c.keep != ""
+			}
+			if !remove {
+				continue
+			}
+			for _, p := range groups[i].Pkgs {
+				if c.dryRun {
+					fmt.Printf("would remove %s\n", p.Path)
+					continue
+				}
+				fmt.Printf("removing %s\n", p.Path)
+				if err := p.Remove(); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return fmt.Errorf("failed to remove %s: %w", p.Path, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func init() {
+	cmd := pruneCommand{}
+	flags := flag.NewFlagSet("prune", flag.ExitOnError)
+	flags.StringVar(&cmd.downloadDir, "download-dir", ".", "download dir")
+	flags.UintVar(&cmd.keepLatest, "keep-latest", 0, "keep only the latest `N` versions of each project")
+	flags.StringVar(&cmd.keep, "keep", "", "always keep the versions matching these version `constraints`")
+	flags.BoolVar(&cmd.dropPrereleases, "drop-prereleases", false, "remove prereleases")
+	flags.BoolVar(&cmd.dryRun, "dry-run", false, "only print what would be removed")
+	flags.IntVar(&cmd.workers, "jobs", pkg.DefaultWorkers(), "number of packages processed concurrently")
+	cmd.flags = flags
+	RegisterCommand(&cmd)
+}
@@ -6,27 +6,236 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"net/http"
+	"log"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"text/template"
+	"time"
 
-	"github.com/hashicorp/go-version"
+	"github.com/montag451/go-pypi-mirror/internal/pep440"
+	"github.com/montag451/go-pypi-mirror/internal/pypi"
+	"github.com/montag451/go-pypi-mirror/pkg"
 )
 
+const defaultQueryURL = "https://pypi.org/pypi/{{ . }}/json"
+
 type queryCommand struct {
-	flags       *flag.FlagSet
-	constraints string
-	latest      uint
-	url         string
-	format      string
+	flags         *flag.FlagSet
+	constraints   string
+	latest        uint
+	url           string
+	format        string
+	username      string
+	password      string
+	token         string
+	caBundle      string
+	downloadDir   string
+	mirrorURL     string
+	pre           bool
+	includeYanked bool
+}
+
+type queryVersion struct {
+	version  *pep440.Version
+	local    bool
+	upstream bool
+	files    []*pypi.File
+}
+
+type queryFile struct {
+	Filename       string `json:"filename"`
+	PackageType    string `json:"packagetype"`
+	PythonVersion  string `json:"python_version"`
+	RequiresPython string `json:"requires_python,omitempty"`
+	Size           int64  `json:"size,omitempty"`
+	SHA256         string `json:"sha256,omitempty"`
+	Yanked         bool   `json:"yanked"`
+	YankedReason   string `json:"yanked_reason,omitempty"`
+	UploadTime     string `json:"upload_time,omitempty"`
+}
+
+type queryRelease struct {
+	Name     string       `json:"name"`
+	Version  string       `json:"version"`
+	Local    bool         `json:"local"`
+	Upstream bool         `json:"upstream"`
+	Files    []*queryFile `json:"files"`
+	location string
+}
+
+func newQueryFile(f *pypi.File) *queryFile {
+	qf := &queryFile{
+		Filename:       f.Filename,
+		PackageType:    f.PackageType,
+		PythonVersion:  f.PythonVersion,
+		RequiresPython: f.RequiresPython,
+		Size:           f.Size,
+		SHA256:         f.Hashes["sha256"],
+		Yanked:         f.Yanked,
+		YankedReason:   f.YankedReason,
+	}
+	if !f.UploadTime.IsZero() {
+		qf.UploadTime = f.UploadTime.UTC().Format(time.RFC3339)
+	}
+	switch f.Kind() {
+	case pkg.KindSdist:
+		if qf.PackageType == "" {
+			qf.PackageType = "sdist"
+		}
+		if qf.PythonVersion == "" {
+			qf.PythonVersion = "source"
+		}
+	case pkg.KindWheel:
+		if qf.PackageType == "" {
+			qf.PackageType = "bdist_wheel"
+		}
+		if info, err := pkg.ParseWheelFilename(f.Filename); err == nil && qf.PythonVersion == "" {
+			qf.PythonVersion = strings.Join(info.PythonTags, ".")
+		}
+	}
+	return qf
+}
+
+func (v *queryVersion) release(name string) *queryRelease {
+	r := &queryRelease{
+		Name:     name,
+		Version:  v.version.Original(),
+		Local:    v.local,
+		Upstream: v.upstream,
+		Files:    make([]*queryFile, 0, len(v.files)),
+		location: v.location(),
+	}
+	for _, f := range v.files {
+		r.Files = append(r.Files, newQueryFile(f))
+	}
+	return r
+}
+
+func printQueryFile(f *queryFile) {
+	fmt.Printf("  %s (%s, %s", f.Filename, f.PackageType, f.PythonVersion)
+	if f.RequiresPython != "" {
+		fmt.Printf(", requires-python %s", f.RequiresPython)
+	}
+	if f.Size > 0 {
+		fmt.Printf(", %d bytes", f.Size)
+	}
+	if f.UploadTime != "" {
+		fmt.Printf(", uploaded %s", f.UploadTime)
+	}
+	fmt.Println(")")
+	if f.SHA256 != "" {
+		fmt.Printf("    sha256:%s\n", f.SHA256)
+	}
+	if f.Yanked {
+		if f.YankedReason != "" {
+			fmt.Printf("    yanked: %s\n", f.YankedReason)
+		} else {
+			fmt.Println("    yanked")
+		}
+	}
+}
+
+func (v *queryVersion) available(includeYanked bool) bool {
+	if len(v.files) == 0 {
+		return false
+	}
+	if includeYanked {
+		return true
+	}
+	for _, f := range v.files {
+		if !f.Yanked {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *queryVersion) location() string {
+	switch {
+	case v.local && v.upstream:
+		return "both"
+	case v.local:
+		return "local"
+	}
+	return "upstream"
 }
 
 func (c *queryCommand) FlagSet() *flag.FlagSet {
 	return c.flags
 }
 
+func (c *queryCommand) newClient(indexURL string) (*pypi.Client, error) {
+	client, err := pypi.NewClient(indexURL, "")
+	if err != nil {
+		return nil, err
+	}
+	client.Username = c.username
+	client.Password = c.password
+	client.Token = c.token
+	if c.caBundle != "" {
+		if err := client.LoadCABundle(c.caBundle); err != nil {
+			return nil, fmt.Errorf("failed to load CA bundle: %w", err)
+		}
+	}
+	return client, nil
+}
+
+func (c *queryCommand) localVersions(ctx context.Context, name string) ([]string, error) {
+	if c.mirrorURL != "" {
+		client, err := c.newClient(c.mirrorURL)
+		if err != nil {
+			return nil, err
+		}
+		project, err := client.Project(ctx, name)
+		if err != nil {
+			if errors.Is(err, pypi.ErrNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return project.Versions, nil
+	}
+	normName := pkg.Normalize(name)
+	var versions []string
+	err := filepath.Walk(c.downloadDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		filename := info.Name()
+		if info.IsDir() || strings.HasPrefix(filename, ".") || !pkg.IsSupported(filename) {
+			return nil
+		}
+		version, err := pkg.VersionFromFilename(filename, name)
+		if err != nil {
+			return nil
+		}
+		if _, err := pep440.Parse(version); err != nil {
+			return nil
+		}
+		project := strings.SplitN(filename, "-", 2)[0]
+		if pkg.KindOf(filename) == pkg.KindSdist {
+			project = filename[:strings.LastIndex(filename, "-"+version)]
+		}
+		if pkg.Normalize(project) != normName {
+			return nil
+		}
+		if meta, err := pkg.ReadMetadataFile(path + pkg.MetadataExt); err == nil && meta != nil {
+			version = meta.Version
+		}
+		versions = append(versions, version)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
 func (c *queryCommand) Execute(ctx context.Context) error {
 	pkgs := c.flags.Args()
 	if nbPkgs := len(pkgs); nbPkgs == 0 {
@@ -37,69 +246,149 @@ func (c *queryCommand) Execute(ctx context.Context) error {
 	if c.url == "" {
 		return fmt.Errorf("empty URL")
 	}
-	t, err := template.New("").Parse(c.url)
+	constraints, err := pep440.ParseSpecifierSet(c.constraints)
 	if err != nil {
-		return fmt.Errorf("invalid URL template %q: %w", c.url, err)
+		return fmt.Errorf("invalid version constraint %q: %w", c.constraints, err)
+	}
+	if c.downloadDir != "" && c.mirrorURL != "" {
+		return errors.New("-download-dir and -mirror-url are mutually exclusive")
 	}
-	var constraints version.Constraints
-	if c.constraints != "" {
-		var err error
-		constraints, err = version.NewConstraint(c.constraints)
+	compare := c.downloadDir != "" || c.mirrorURL != ""
+	var tmpl *template.Template
+	switch c.format {
+	case "", "oneline", "json", "files", "files-json":
+	default:
+		if !strings.Contains(c.format, "{{") {
+			return fmt.Errorf("unknown output format %q", c.format)
+		}
+		tmpl, err = template.New("format").Parse(c.format)
 		if err != nil {
-			return fmt.Errorf("invalid version constraint %q: %w", c.constraints, err)
+			return fmt.Errorf("invalid format template %q: %w", c.format, err)
 		}
 	}
-	var url strings.Builder
-	if err := t.Execute(&url, pkgs[0]); err != nil {
-		return fmt.Errorf("failed to execute URL template %q: %w", c.url, err)
-	}
-	req, err := http.NewRequestWithContext(ctx, "GET", url.String(), nil)
+	client, err := c.newClient(c.url)
 	if err != nil {
-		return fmt.Errorf("failed to get %q: %w", url.String(), err)
+		return err
 	}
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
+	project, err := client.Project(ctx, pkgs[0])
+	if err != nil && !(compare && errors.Is(err, pypi.ErrNotFound)) {
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("failed to get %q, HTTP code: %v", url.String(), resp.StatusCode)
+	var upstreamVersions, localVersions []string
+	if project != nil {
+		upstreamVersions = project.Versions
+	}
+	if compare {
+		localVersions, err = c.localVersions(ctx, pkgs[0])
+		if err != nil {
+			return err
+		}
 	}
-	var info map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
-		return fmt.Errorf("failed to parse response: %v", err)
+	byVersion := make(map[string]*queryVersion)
+	var versions []*pep440.Version
+	add := func(rawVersions []string, local bool) {
+		for _, rawVersion := range rawVersions {
+			version, err := pep440.Parse(rawVersion)
+			if err != nil {
+				log.Printf("ignoring release %q: %v", rawVersion, err)
+				continue
+			}
+			if !constraints.Contains(version, c.pre) {
+				continue
+			}
+			v, ok := byVersion[version.String()]
+			if !ok {
+				v = &queryVersion{version: version}
+				byVersion[version.String()] = v
+			}
+			if local {
+				v.local = true
+			} else {
+				v.upstream = true
+			}
+		}
 	}
-	releases, ok := info["releases"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("failed to parse response, missing or invalid key: %q", "releases")
+	add(upstreamVersions, false)
+	add(localVersions, true)
+	if project != nil {
+		for _, f := range project.Files {
+			version, err := pep440.Parse(f.Version)
+			if err != nil {
+				continue
+			}
+			if v, ok := byVersion[version.String()]; ok {
+				v.files = append(v.files, f)
+			}
+		}
 	}
-	var versions []*version.Version
-	for rawVersion, _ := range releases {
-		version, err := version.NewVersion(rawVersion)
-		if err != nil {
-			return fmt.Errorf("unable to parse version %q: %w", rawVersion, err)
+	pinned := isPinned(constraints)
+	for _, v := range byVersion {
+		if v.upstream && !v.available(c.includeYanked || pinned) {
+			v.upstream = false
 		}
-		if c.constraints == "" || constraints.Check(version) {
-			versions = append(versions, version)
+		if v.local || v.upstream {
+			versions = append(versions, v.version)
 		}
 	}
-	sort.Sort(version.Collection(versions))
-	if c.latest > 0 {
+	name := pkgs[0]
+	if project != nil && project.Name != "" {
+		name = project.Name
+	}
+	var releases []*queryRelease
+	sort.Sort(pep440.Collection(versions))
+	if c.latest > 0 && int(c.latest) < len(versions) {
 		versions = versions[len(versions)-int(c.latest):]
 	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		releases = append(releases, byVersion[versions[i].String()].release(name))
+	}
 	switch c.format {
 	case "", "oneline":
 		for i := len(versions) - 1; i >= 0; i-- {
+			if compare {
+				fmt.Printf("%s %s\n", versions[i], byVersion[versions[i].String()].location())
+				continue
+			}
 			fmt.Println(versions[i])
 		}
 	case "json":
+		if compare {
+			entries := make([]map[string]interface{}, 0, len(versions))
+			for i := len(versions) - 1; i >= 0; i-- {
+				v := byVersion[versions[i].String()]
+				entries = append(entries, map[string]interface{}{
+					"version":  versions[i].Original(),
+					"local":    v.local,
+					"upstream": v.upstream,
+				})
+			}
+			return json.NewEncoder(os.Stdout).Encode(entries)
+		}
 		reversedVersions := make([]string, 0, len(versions))
 		for i := len(versions) - 1; i >= 0; i-- {
 			reversedVersions = append(reversedVersions, versions[i].Original())
 		}
 		json.NewEncoder(os.Stdout).Encode(reversedVersions)
+	case "files":
+		for _, r := range releases {
+			if compare {
+				fmt.Printf("%s (%s)\n", r.Version, r.location)
+			} else {
+				fmt.Println(r.Version)
+			}
+			for _, f := range r.Files {
+				printQueryFile(f)
+			}
+		}
+	case "files-json":
+		return json.NewEncoder(os.Stdout).Encode(releases)
 	default:
-		return fmt.Errorf("unknown output format %q", c.format)
+		for _, r := range releases {
+			if err := tmpl.Execute(os.Stdout, r); err != nil {
+				return fmt.Errorf("failed to execute format template: %w", err)
+			}
+			fmt.Println()
+		}
 	}
 	return nil
 }
@@ -108,9 +397,17 @@ func init() {
 	cmd := queryCommand{}
 	flags := flag.NewFlagSet("query", flag.ExitOnError)
 	flags.StringVar(&cmd.constraints, "constraints", "", "version constraints")
+	flags.BoolVar(&cmd.pre, "pre", false, "include pre-release and development versions")
+	flags.BoolVar(&cmd.includeYanked, "include-yanked", false, "include yanked versions")
 	flags.UintVar(&cmd.latest, "latest", 0, "list only the latest `N` versions")
-	flags.StringVar(&cmd.url, "url", "https://pypi.org/pypi/{{ . }}/json", "index URL template")
-	flags.StringVar(&cmd.format, "format", "oneline", "output format (oneline or json)")
+	flags.StringVar(&cmd.url, "url", defaultQueryURL, "index URL template, JSON API or simple API (PEP 503 or PEP 691) project page")
+	flags.StringVar(&cmd.username, "username", "", "username used to authenticate against the index")
+	flags.StringVar(&cmd.password, "password", "", "password used to authenticate against the index")
+	flags.StringVar(&cmd.token, "token", "", "bearer token used to authenticate against the index")
+	flags.StringVar(&cmd.caBundle, "ca-bundle", "", "PEM `file` containing the CA certificates used to verify the index")
+	flags.StringVar(&cmd.downloadDir, "download-dir", "", "compare the upstream versions with the ones present in this download dir")
+	flags.StringVar(&cmd.mirrorURL, "mirror-url", "", "compare the upstream versions with the ones served by this mirror simple index `URL`")
+	flags.StringVar(&cmd.format, "format", "oneline", "output format (oneline, json, files, files-json or a Go template executed for each version)")
 	flags.Usage = func() {
 		fmt.Fprintf(flags.Output(), "Usage: %s [options] pkg\n", flags.Name())
 		fmt.Fprintln(flags.Output(), "Options:")
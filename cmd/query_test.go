@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestQueryLocalVersions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := []string{
+		"Foo.Bar-1.0.tar.gz",
+		"foo_bar-2.0-py3-none-any.whl",
+		"foo-bar-3.0-1.zip",
+		"foo-1.0-py3-none-any.whl",
+		"foo-bar-baz-1.0.tar.gz",
+		".foo_bar-4.0-py3-none-any.whl",
+		"foo_bar-5.0.tar.gz.part",
+	}
+	for _, name := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("not an archive"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &queryCommand{downloadDir: dir}
+	versions, err := c.localVersions(context.Background(), "foo-bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(versions)
+	if got, want := strings.Join(versions, " "), "1.0 2.0 3.0-1"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(files) {
+		t.Errorf("query wrote to the download dir: %d entries, want %d", len(entries), len(files))
+	}
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/montag451/go-pypi-mirror/pkg"
+)
+
+const simplePrefix = "/simple/"
+
+type simpleIndex struct {
+	rootPkgs []*pkg.Pkg
+	projects map[string][]*pkg.Pkg
+}
+
+func newSimpleIndex(pkgs []*pkg.Pkg) *simpleIndex {
+	groups := pkg.GroupByNormName(pkgs)
+	idx := &simpleIndex{
+		rootPkgs: make([]*pkg.Pkg, 0, len(groups)),
+		projects: make(map[string][]*pkg.Pkg, len(groups)),
+	}
+	for _, group := range groups {
+		pkgs := group.Pkgs
+		pkg.FixNames(pkgs)
+		idx.projects[group.Key.(string)] = pkgs
+		idx.rootPkgs = append(idx.rootPkgs, pkgs[0])
+	}
+	return idx
+}
+
+func (idx *simpleIndex) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Path == "/" || r.URL.Path == strings.TrimSuffix(simplePrefix, "/") {
+		http.Redirect(w, r, simplePrefix, http.StatusMovedPermanently)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, simplePrefix) {
+		http.NotFound(w, r)
+		return
+	}
+	p := strings.TrimPrefix(r.URL.Path, simplePrefix)
+	if contentType, ok := indexContentType(r, p); ok {
+		idx.serveRoot(w, r, contentType)
+		return
+	}
+	components := strings.SplitN(p, "/", 2)
+	normName := pkg.Normalize(components[0])
+	pkgs, ok := idx.projects[normName]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if len(components) == 1 || normName != components[0] {
+		target := simplePrefix + normName + "/"
+		if len(components) == 2 {
+			target += components[1]
+		}
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return
+	}
+	filename := components[1]
+	if contentType, ok := indexContentType(r, filename); ok {
+		idx.serveProject(w, r, pkgs, contentType)
+		return
+	}
+	for _, p := range pkgs {
+		if p.Filename == filename {
+			http.ServeFile(w, r, p.Path)
+			return
+		}
+		if p.Metadata.CoreMetadataHash != "" && p.Filename+".metadata" == filename {
+			idx.serveCoreMetadata(w, r, p)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func (idx *simpleIndex) serveCoreMetadata(w http.ResponseWriter, r *http.Request, p *pkg.Pkg) {
+	data, err := p.CoreMetadata()
+	if err != nil {
+		log.Print(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+var simpleContentTypes = map[string]string{
+	"application/vnd.pypi.simple.v1+json":     simpleJSONContentType,
+	"application/vnd.pypi.simple.latest+json": simpleJSONContentType,
+	"application/vnd.pypi.simple.v1+html":     simpleHTMLContentType,
+	"application/vnd.pypi.simple.latest+html": simpleHTMLContentType,
+	"text/html":     "text/html",
+	"text/*":        "text/html",
+	"application/*": simpleJSONContentType,
+	"*/*":           "text/html",
+}
+
+func negotiateContentType(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return simpleContentTypes[format]
+	}
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return "text/html"
+	}
+	var best string
+	bestQ := 0.0
+	for _, rng := range strings.Split(accept, ",") {
+		params := strings.Split(rng, ";")
+		contentType, ok := simpleContentTypes[strings.ToLower(strings.TrimSpace(params[0]))]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = contentType, q
+		}
+	}
+	return best
+}
+
+func indexContentType(r *http.Request, filename string) (string, bool) {
+	switch filename {
+	case "":
+		return negotiateContentType(r), true
+	case "index.html":
+		return "text/html", true
+	case "index.json":
+		return simpleJSONContentType, true
+	}
+	return "", false
+}
+
+func (idx *simpleIndex) serveIndex(w http.ResponseWriter, contentType, name string, pkgs []*pkg.Pkg, generateHTML, generateJSON func(io.Writer, []*pkg.Pkg) error) {
+	w.Header().Set("Vary", "Accept")
+	generate := generateHTML
+	switch contentType {
+	case "":
+		http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+		return
+	case simpleJSONContentType:
+		generate = generateJSON
+	case "text/html":
+		contentType += "; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	if err := generate(w, pkgs); err != nil {
+		log.Printf("failed to generate index of %s: %v", name, err)
+	}
+}
+
+func (idx *simpleIndex) serveRoot(w http.ResponseWriter, r *http.Request, contentType string) {
+	idx.serveIndex(w, contentType, "root", idx.rootPkgs, generateRootHTML, generateRootJSON)
+}
+
+func (idx *simpleIndex) serveProject(w http.ResponseWriter, r *http.Request, pkgs []*pkg.Pkg, contentType string) {
+	name := strconv.Quote(pkgs[0].Metadata.NormName)
+	idx.serveIndex(w, contentType, name, pkgs, generatePackageHTML, generatePackageJSON)
+}
+
+type serveCommand struct {
+	flags           *flag.FlagSet
+	downloadDir     string
+	addr            string
+	shutdownTimeout time.Duration
+	workers         int
+}
+
+func (c *serveCommand) FlagSet() *flag.FlagSet {
+	return c.flags
+}
+
+func (c *serveCommand) Execute(ctx context.Context) error {
+	downloadDir, err := filepath.Abs(c.downloadDir)
+	if err != nil {
+		return err
+	}
+	pkgs, err := pkg.List(ctx, downloadDir, false, c.workers)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Addr:    c.addr,
+		Handler: newSimpleIndex(pkgs),
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("serving %s on %s", downloadDir, c.addr)
+		errCh <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), c.shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shutdown server: %w", err)
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func init() {
+	cmd := serveCommand{}
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.StringVar(&cmd.downloadDir, "download-dir", ".", "download dir")
+	flags.StringVar(&cmd.addr, "addr", ":8080", "listen address")
+	flags.DurationVar(&cmd.shutdownTimeout, "shutdown-timeout", 5*time.Second, "maximum time to wait for in-flight requests on shutdown")
+	flags.IntVar(&cmd.workers, "jobs", pkg.DefaultWorkers(), "number of packages processed concurrently")
+	cmd.flags = flags
+	RegisterCommand(&cmd)
+}
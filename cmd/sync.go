@@ -0,0 +1,326 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/montag451/go-pypi-mirror/internal/flagutil"
+	"github.com/montag451/go-pypi-mirror/internal/pep440"
+	"github.com/montag451/go-pypi-mirror/internal/pypi"
+	"github.com/montag451/go-pypi-mirror/pkg"
+)
+
+var syncFlagKeys = map[string]string{
+	"download-dir":     "download-dir",
+	"mirror-dir":       "mirror-dir",
+	"jobs":             "jobs",
+	"index-url":        "source.index-url",
+	"proxy":            "source.proxy",
+	"native":           "source.native",
+	"pip":              "source.pip",
+	"query-url":        "source.query-url",
+	"requirements":     "packages.requirements",
+	"lock":             "packages.locks",
+	"allow-binary":     "packages.allow-binary",
+	"no-deps":          "packages.no-deps",
+	"keep-latest":      "retention.keep-latest",
+	"keep":             "retention.keep",
+	"drop-prereleases": "retention.drop-prereleases",
+	"copy":             "output.copy",
+	"generations":      "output.generations",
+}
+
+type syncCommand struct {
+	flags     *flag.FlagSet
+	config    string
+	overrides syncConfig
+}
+
+func (c *syncCommand) FlagSet() *flag.FlagSet {
+	return c.flags
+}
+
+func (c *syncCommand) loadConfig() (*syncConfig, error) {
+	cfg := &syncConfig{downloadDir: ".", jobs: pkg.DefaultWorkers()}
+	if c.config != "" {
+		var err error
+		if cfg, err = loadSyncConfig(c.config); err != nil {
+			return nil, err
+		}
+		if cfg.downloadDir == "" {
+			cfg.downloadDir = "."
+		}
+		if cfg.jobs == 0 {
+			cfg.jobs = pkg.DefaultWorkers()
+		}
+	}
+	fields := cfg.fields()
+	overrides := c.overrides.fields()
+	c.flags.Visit(func(f *flag.Flag) {
+		if key, ok := syncFlagKeys[f.Name]; ok {
+			copyConfigValue(fields[key], overrides[key])
+		}
+	})
+	if args := c.flags.Args(); len(args) > 0 {
+		cfg.packages = args
+	}
+	return cfg, nil
+}
+
+func (c *syncCommand) run(ctx context.Context, name string, args []string) error {
+	fmt.Printf("==> %s\n", name)
+	if err := Execute(ctx, name, args); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+type releaseFilter struct {
+	binaryOnly bool
+	tags       pkg.TagSet
+}
+
+func releaseFilters(cfg *syncConfig) ([]releaseFilter, error) {
+	if cfg.targetsFile == "" {
+		return []releaseFilter{{}}, nil
+	}
+	targets, err := loadTargets(cfg.targetsFile)
+	if err != nil {
+		return nil, err
+	}
+	filters := make([]releaseFilter, 0, len(targets))
+	for _, t := range targets {
+		f := releaseFilter{binaryOnly: t.binaryOnly()}
+		if f.binaryOnly && t.pythonVersion != "" {
+			if f.tags, err = pkg.SupportedTags(t.pythonVersion, t.implementation, t.abis, t.platforms); err != nil {
+				return nil, err
+			}
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+func (f releaseFilter) accept(file *pypi.File, allowBinary bool) bool {
+	if file.Kind() != pkg.KindWheel {
+		return !f.binaryOnly
+	}
+	if !f.binaryOnly {
+		return allowBinary
+	}
+	if f.tags == nil {
+		return true
+	}
+	info, err := pkg.ParseWheelFilename(file.Filename)
+	return err == nil && f.tags.Supports(info.Tags())
+}
+
+func (c *syncCommand) newReleases(ctx context.Context, cfg *syncConfig) ([]string, error) {
+	pkgs, err := pkg.List(ctx, cfg.downloadDir, true, cfg.jobs)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]*pep440.Version)
+	for _, p := range pkgs {
+		v, err := pep440.Parse(p.Metadata.Version)
+		if err != nil {
+			continue
+		}
+		if l, ok := latest[p.Metadata.NormName]; !ok || v.GreaterThan(l) {
+			latest[p.Metadata.NormName] = v
+		}
+	}
+	queryURL := cfg.queryURL
+	if queryURL == "" {
+		queryURL = defaultQueryURL
+	}
+	client, err := pypi.NewClient(queryURL, cfg.proxy)
+	if err != nil {
+		return nil, err
+	}
+	filters, err := releaseFilters(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var pins []string
+	for _, tracked := range cfg.projects {
+		constraints, err := pep440.ParseSpecifierSet(tracked.constraints)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint %q for %s: %w", tracked.constraints, tracked.name, err)
+		}
+		project, err := client.Project(ctx, tracked.name)
+		if err != nil {
+			return nil, err
+		}
+		served := make(map[string][]bool)
+		for _, f := range project.Files {
+			if f.Yanked || !pkg.IsSupported(f.Filename) {
+				continue
+			}
+			if served[f.Version] == nil {
+				served[f.Version] = make([]bool, len(filters))
+			}
+			for i, filter := range filters {
+				if filter.accept(f, cfg.allowBinary) {
+					served[f.Version][i] = true
+				}
+			}
+		}
+		local := latest[pkg.Normalize(tracked.name)]
+		var versions []*pep440.Version
+		for raw, targets := range served {
+			if !allTrue(targets) {
+				continue
+			}
+			v, err := pep440.Parse(raw)
+			if err != nil {
+				log.Printf("ignoring release %q of %s: %v", raw, tracked.name, err)
+				continue
+			}
+			if !constraints.Contains(v, false) {
+				continue
+			}
+			if local != nil && !v.GreaterThan(local) {
+				continue
+			}
+			versions = append(versions, v)
+		}
+		sort.Sort(pep440.Collection(versions))
+		if local == nil && len(versions) > 1 {
+			versions = versions[len(versions)-1:]
+		}
+		for _, v := range versions {
+			fmt.Printf("%s: new release %s\n", tracked.name, v.Original())
+			pins = append(pins, tracked.name+"=="+v.Original())
+		}
+	}
+	return pins, nil
+}
+
+func allTrue(values []bool) bool {
+	for _, v := range values {
+		if !v {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *syncCommand) Execute(ctx context.Context) error {
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.mirrorDir == "" {
+		return errors.New("no mirror dir specified")
+	}
+	if err := os.MkdirAll(cfg.downloadDir, 0755); err != nil {
+		return err
+	}
+	var pins []string
+	if len(cfg.projects) > 0 {
+		fmt.Println("==> track")
+		if pins, err = c.newReleases(ctx, cfg); err != nil {
+			return err
+		}
+	}
+	jobs := strconv.Itoa(cfg.jobs)
+	if len(cfg.packages) > 0 || len(pins) > 0 || len(cfg.requirements) > 0 || len(cfg.locks) > 0 {
+		args := []string{"-download-dir", cfg.downloadDir, "-jobs", jobs}
+		if cfg.indexURL != "" {
+			args = append(args, "-index-url", cfg.indexURL)
+		}
+		if cfg.proxy != "" {
+			args = append(args, "-proxy", cfg.proxy)
+		}
+		if cfg.native {
+			args = append(args, "-native")
+		}
+		if cfg.pip != "" {
+			args = append(args, "-pip", cfg.pip)
+		}
+		if cfg.allowBinary {
+			args = append(args, "-allow-binary")
+		}
+		if cfg.noDeps {
+			args = append(args, "-no-deps")
+		}
+		if cfg.targetsFile != "" {
+			args = append(args, "-targets-file", cfg.targetsFile)
+		}
+		for _, r := range cfg.requirements {
+			args = append(args, "-requirements", r)
+		}
+		for _, l := range cfg.locks {
+			args = append(args, "-lock", l)
+		}
+		args = append(args, cfg.packages...)
+		args = append(args, pins...)
+		if err := c.run(ctx, "download", args); err != nil {
+			return err
+		}
+	}
+	if err := c.run(ctx, "write-metadata", []string{"-download-dir", cfg.downloadDir, "-jobs", jobs}); err != nil {
+		return err
+	}
+	if cfg.keepLatest > 0 || cfg.keep != "" || cfg.dropPrereleases {
+		args := []string{"-download-dir", cfg.downloadDir, "-jobs", jobs}
+		if cfg.keepLatest > 0 {
+			args = append(args, "-keep-latest", strconv.Itoa(cfg.keepLatest))
+		}
+		if cfg.keep != "" {
+			args = append(args, "-keep", cfg.keep)
+		}
+		if cfg.dropPrereleases {
+			args = append(args, "-drop-prereleases")
+		}
+		if err := c.run(ctx, "prune", args); err != nil {
+			return err
+		}
+	}
+	args := []string{"-download-dir", cfg.downloadDir, "-mirror-dir", cfg.mirrorDir, "-jobs", jobs}
+	if cfg.copy {
+		args = append(args, "-copy")
+	}
+	if cfg.generations > 0 {
+		args = append(args, "-generations", strconv.Itoa(cfg.generations))
+	}
+	return c.run(ctx, "create", args)
+}
+
+func init() {
+	cmd := syncCommand{}
+	o := &cmd.overrides
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	flags.StringVar(&cmd.config, "config", "", "TOML configuration `file` describing the mirror")
+	flags.StringVar(&o.downloadDir, "download-dir", ".", "download dir")
+	flags.StringVar(&o.mirrorDir, "mirror-dir", "", "mirror dir")
+	flags.IntVar(&o.jobs, "jobs", pkg.DefaultWorkers(), "number of packages processed concurrently")
+	flags.StringVar(&o.indexURL, "index-url", "", "index URL")
+	flags.StringVar(&o.proxy, "proxy", "", "proxy address in the form [user:passwd@]proxy.server:port")
+	flags.BoolVar(&o.native, "native", false, "resolve and download packages without pip")
+	flags.StringVar(&o.pip, "pip", "", "pip executable")
+	flags.StringVar(&o.queryURL, "query-url", defaultQueryURL, "URL template used to look up new releases of the tracked projects")
+	flags.Var((*flagutil.StringSlice)(&o.requirements), "requirements", "requirements file")
+	flags.Var((*flagutil.StringSlice)(&o.locks), "lock", "lock file")
+	flags.BoolVar(&o.allowBinary, "allow-binary", false, "allow binary")
+	flags.BoolVar(&o.noDeps, "no-deps", false, "don't download dependencies (native mode only)")
+	flags.IntVar(&o.keepLatest, "keep-latest", 0, "keep only the latest `N` versions of each project")
+	flags.StringVar(&o.keep, "keep", "", "always keep the versions matching these version `constraints`")
+	flags.BoolVar(&o.dropPrereleases, "drop-prereleases", false, "remove prereleases")
+	flags.BoolVar(&o.copy, "copy", false, "copy instead of symlinking packages")
+	flags.IntVar(&o.generations, "generations", 0, "keep the last `N` generations of the mirror (0 to update the mirror dir in place)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [options] [pkgs]\n", flags.Name())
+		fmt.Fprintln(flags.Output(), "Options:")
+		flags.PrintDefaults()
+	}
+	cmd.flags = flags
+	RegisterCommand(&cmd)
+}
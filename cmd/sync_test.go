@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewReleasesFiltersTargets(t *testing.T) {
+	files := []string{
+		"foo-1.0.tar.gz",
+		"foo-1.0-cp38-cp38-manylinux1_x86_64.whl",
+		"foo-1.0-cp39-cp39-manylinux1_x86_64.whl",
+		"foo-1.1-cp38-cp38-manylinux1_x86_64.whl",
+		"foo-1.2.tar.gz",
+		"foo-1.3-py3-none-any.whl",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		for _, f := range files {
+			fmt.Fprintf(w, "<a href=\"/files/%s\">%s</a>\n", f, f)
+		}
+	}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	downloadDir := filepath.Join(dir, "download")
+	if err := os.Mkdir(downloadDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	local := testDist{"foo", "0.9", nil}
+	if err := ioutil.WriteFile(filepath.Join(downloadDir, local.filename()), local.wheel(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+	targetsFile := filepath.Join(dir, "targets.toml")
+	targets := `[matrix]
+python-version = ["3.8", "3.9"]
+platform = "manylinux1_x86_64"
+`
+	if err := ioutil.WriteFile(targetsFile, []byte(targets), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		targetsFile string
+		allowBinary bool
+		want        string
+	}{
+		{"sdist only", "", false, "foo==1.0,foo==1.2"},
+		{"binary allowed", "", true, "foo==1.0,foo==1.1,foo==1.2,foo==1.3"},
+		{"targets", targetsFile, false, "foo==1.0,foo==1.3"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &syncConfig{
+				downloadDir: downloadDir,
+				jobs:        1,
+				queryURL:    srv.URL + "/simple/{{ . }}/",
+				allowBinary: test.allowBinary,
+				targetsFile: test.targetsFile,
+				projects:    []trackedProject{{name: "foo", constraints: ">=1.0"}},
+			}
+			pins, err := (&syncCommand{}).newReleases(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(pins, ","); got != test.want {
+				t.Errorf("got pins %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/montag451/go-pypi-mirror/internal/toml"
+)
+
+type downloadTarget struct {
+	pythonVersion  string
+	implementation string
+	platforms      []string
+	abis           []string
+}
+
+func (t *downloadTarget) binaryOnly() bool {
+	return len(t.platforms) > 0 || t.pythonVersion != "" || t.implementation != "" || len(t.abis) > 0
+}
+
+func (t *downloadTarget) String() string {
+	var components []string
+	if t.pythonVersion != "" {
+		components = append(components, "python-version="+t.pythonVersion)
+	}
+	if t.implementation != "" {
+		components = append(components, "implementation="+t.implementation)
+	}
+	for _, p := range t.platforms {
+		components = append(components, "platform="+p)
+	}
+	for _, a := range t.abis {
+		components = append(components, "abi="+a)
+	}
+	if len(components) == 0 {
+		return "default"
+	}
+	return strings.Join(components, ",")
+}
+
+func (t *downloadTarget) set(key string, value string) error {
+	switch key {
+	case "python-version":
+		if t.pythonVersion != "" {
+			return fmt.Errorf("%q specified more than once", key)
+		}
+		t.pythonVersion = value
+	case "implementation":
+		if t.implementation != "" {
+			return fmt.Errorf("%q specified more than once", key)
+		}
+		t.implementation = value
+	case "platform":
+		t.platforms = append(t.platforms, value)
+	case "abi":
+		t.abis = append(t.abis, value)
+	default:
+		return fmt.Errorf("unknown target key %q", key)
+	}
+	return nil
+}
+
+func (t *downloadTarget) inherit(base *downloadTarget) {
+	if t.pythonVersion == "" {
+		t.pythonVersion = base.pythonVersion
+	}
+	if t.implementation == "" {
+		t.implementation = base.implementation
+	}
+	if len(t.platforms) == 0 {
+		t.platforms = base.platforms
+	}
+	if len(t.abis) == 0 {
+		t.abis = base.abis
+	}
+}
+
+func parseTarget(s string) (*downloadTarget, error) {
+	t := &downloadTarget{}
+	for _, kv := range strings.Split(s, ",") {
+		components := strings.SplitN(kv, "=", 2)
+		if len(components) != 2 {
+			return nil, fmt.Errorf("invalid target %q, expected key=value[,key=value...]", s)
+		}
+		if err := t.set(strings.TrimSpace(components[0]), strings.TrimSpace(components[1])); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func stringList(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a string, got %v", e)
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("expected a string or an array of strings, got %v", v)
+}
+
+func targetsFromTable(t map[string]interface{}, cross bool) ([]*downloadTarget, error) {
+	targets := []*downloadTarget{{}}
+	for _, key := range []string{"python-version", "implementation", "platform", "abi"} {
+		v, ok := t[key]
+		if !ok {
+			continue
+		}
+		values, err := stringList(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %q: %w", key, err)
+		}
+		if !cross || key == "abi" {
+			for _, target := range targets {
+				for _, value := range values {
+					if err := target.set(key, value); err != nil {
+						return nil, err
+					}
+				}
+			}
+			continue
+		}
+		expanded := make([]*downloadTarget, 0, len(targets)*len(values))
+		for _, target := range targets {
+			for _, value := range values {
+				next := *target
+				next.platforms = append([]string(nil), target.platforms...)
+				if err := next.set(key, value); err != nil {
+					return nil, err
+				}
+				expanded = append(expanded, &next)
+			}
+		}
+		targets = expanded
+	}
+	for key := range t {
+		if err := (&downloadTarget{}).set(key, ""); err != nil {
+			return nil, err
+		}
+	}
+	return targets, nil
+}
+
+func loadTargets(path string) ([]*downloadTarget, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	doc, err := toml.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", path, err)
+	}
+	var targets []*downloadTarget
+	if matrix, ok := doc["matrix"].(map[string]interface{}); ok {
+		expanded, err := targetsFromTable(matrix, true)
+		if err != nil {
+			return nil, fmt.Errorf("%s: matrix: %w", path, err)
+		}
+		targets = append(targets, expanded...)
+	}
+	entries, _ := doc["target"].([]interface{})
+	for i, e := range entries {
+		table, ok := e.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: target %d is not a table", path, i)
+		}
+		expanded, err := targetsFromTable(table, false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: target %d: %w", path, i, err)
+		}
+		targets = append(targets, expanded...)
+	}
+	return targets, nil
+}
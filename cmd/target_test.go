@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTargets(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []string
+		ok   bool
+	}{
+		{
+			name: "matrix",
+			raw:  "[matrix]\npython-version = [\"3.8\", \"3.9\"]\nplatform = [\"linux_x86_64\", \"win_amd64\"]\nabi = [\"cp38\", \"none\"]\n",
+			want: []string{
+				"python-version=3.8,platform=linux_x86_64,abi=cp38,abi=none",
+				"python-version=3.8,platform=win_amd64,abi=cp38,abi=none",
+				"python-version=3.9,platform=linux_x86_64,abi=cp38,abi=none",
+				"python-version=3.9,platform=win_amd64,abi=cp38,abi=none",
+			},
+			ok: true,
+		},
+		{
+			name: "targets",
+			raw:  "[[target]]\npython-version = \"3.8\"\nplatform = [\"manylinux2014_x86_64\", \"linux_x86_64\"]\n\n[[target]]\nimplementation = \"pp\"\n",
+			want: []string{
+				"python-version=3.8,platform=manylinux2014_x86_64,platform=linux_x86_64",
+				"implementation=pp",
+			},
+			ok: true,
+		},
+		{
+			name: "array of python versions in a target",
+			raw:  "[[target]]\npython-version = [\"3.8\", \"3.9\"]\n",
+		},
+		{
+			name: "array of implementations in a target",
+			raw:  "[[target]]\nimplementation = [\"cp\", \"pp\"]\n",
+		},
+		{
+			name: "unknown key",
+			raw:  "[[target]]\nos = \"linux\"\n",
+		},
+	}
+	dir, err := ioutil.TempDir("", "targets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("%d.toml", i))
+			if err := ioutil.WriteFile(path, []byte(test.raw), 0644); err != nil {
+				t.Fatal(err)
+			}
+			targets, err := loadTargets(path)
+			if !test.ok {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", targets)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, target := range targets {
+				got = append(got, target.String())
+			}
+			if strings.Join(got, " ") != strings.Join(test.want, " ") {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseTargetRepeatedScalarKey(t *testing.T) {
+	if _, err := parseTarget("python-version=3.8,python-version=3.9"); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/montag451/go-pypi-mirror/internal/flagutil"
+	"github.com/montag451/go-pypi-mirror/internal/fsutil"
+	"github.com/montag451/go-pypi-mirror/internal/pep440"
+	"github.com/montag451/go-pypi-mirror/internal/pool"
+	"github.com/montag451/go-pypi-mirror/internal/pypi"
+	"github.com/montag451/go-pypi-mirror/pkg"
+)
+
+const upstreamStateFilename = ".mirror-state.json"
+
+type upstreamState struct {
+	IndexURL   string           `json:"index_url"`
+	Filters    string           `json:"filters"`
+	LastSerial int64            `json:"last_serial"`
+	Projects   map[string]int64 `json:"projects"`
+}
+
+func loadUpstreamState(path string, indexURL string, filters string) (*upstreamState, error) {
+	state := &upstreamState{IndexURL: indexURL, Filters: filters, Projects: make(map[string]int64)}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return state, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var saved upstreamState
+	if err := json.NewDecoder(f).Decode(&saved); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %q: %w", path, err)
+	}
+	if saved.IndexURL != indexURL || saved.Filters != filters || saved.Projects == nil {
+		log.Printf("ignoring state file %q recorded for another index or other filters", path)
+		return state, nil
+	}
+	return &saved, nil
+}
+
+func (s *upstreamState) save(path string) error {
+	return fsutil.WriteFile(path, 0644, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(s)
+	})
+}
+
+type mirrorUpstreamCommand struct {
+	flags       *flag.FlagSet
+	downloadDir string
+	indexURL    string
+	proxy       string
+	allow       flagutil.StringSlice
+	deny        flagutil.StringSlice
+	constraints string
+	kind        string
+	platform    flagutil.StringSlice
+	stateFile   string
+	workers     int
+}
+
+func (c *mirrorUpstreamCommand) FlagSet() *flag.FlagSet {
+	return c.flags
+}
+
+func (c *mirrorUpstreamCommand) filters() string {
+	return fmt.Sprintf("allow=%q deny=%q constraints=%q kind=%q platform=%q", c.allow, c.deny, c.constraints, c.kind, c.platform)
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *mirrorUpstreamCommand) acceptProject(name string) bool {
+	norm := pkg.Normalize(name)
+	if len(c.allow) > 0 && !matchAny(c.allow, norm) {
+		return false
+	}
+	return !matchAny(c.deny, norm)
+}
+
+func (c *mirrorUpstreamCommand) acceptFile(f *pypi.File, constraints pep440.SpecifierSet) bool {
+	if f.Yanked || !pkg.IsSupported(f.Filename) {
+		return false
+	}
+	kind := f.Kind()
+	if c.kind != "" && string(kind) != c.kind {
+		return false
+	}
+	if len(constraints) > 0 {
+		v, err := pep440.Parse(f.Version)
+		if err != nil || !constraints.Contains(v, true) {
+			return false
+		}
+	}
+	if kind != pkg.KindWheel || len(c.platform) == 0 {
+		return true
+	}
+	info, err := pkg.ParseWheelFilename(f.Filename)
+	if err != nil {
+		return false
+	}
+	for _, t := range info.Tags() {
+		if matchAny(c.platform, t.Platform) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *mirrorUpstreamCommand) mirrorProject(ctx context.Context, client *pypi.Client, name string, constraints pep440.SpecifierSet) error {
+	project, err := client.Project(ctx, name)
+	if err != nil {
+		return err
+	}
+	for _, f := range project.Files {
+		if !c.acceptFile(f, constraints) {
+			continue
+		}
+		dest, downloaded, err := client.Download(ctx, f, c.downloadDir)
+		if err != nil {
+			return err
+		}
+		if !downloaded {
+			if _, err := os.Stat(dest + pkg.MetadataExt); err == nil {
+				continue
+			}
+		} else {
+			fmt.Printf("Saved %s\n", dest)
+			if err := removeIfExists(dest + pkg.MetadataExt); err != nil {
+				return err
+			}
+		}
+		p, err := pkg.New(dest)
+		if err != nil {
+			return err
+		}
+		if err := writeMetadataFile(client, p, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *mirrorUpstreamCommand) Execute(ctx context.Context) error {
+	switch c.kind {
+	case "", string(pkg.KindSdist), string(pkg.KindWheel):
+	default:
+		return fmt.Errorf("unknown kind %q", c.kind)
+	}
+	constraints, err := pep440.ParseSpecifierSet(c.constraints)
+	if err != nil {
+		return fmt.Errorf("invalid version constraint %q: %w", c.constraints, err)
+	}
+	if err := os.MkdirAll(c.downloadDir, 0755); err != nil {
+		return err
+	}
+	stateFile := c.stateFile
+	if stateFile == "" {
+		stateFile = filepath.Join(c.downloadDir, upstreamStateFilename)
+	}
+	client, err := pypi.NewClient(c.indexURL, c.proxy)
+	if err != nil {
+		return err
+	}
+	state, err := loadUpstreamState(stateFile, client.URL, c.filters())
+	if err != nil {
+		return err
+	}
+	refs, serial, err := client.Projects(ctx)
+	if err != nil {
+		return err
+	}
+	if serial != 0 && serial == state.LastSerial {
+		fmt.Printf("upstream unchanged since serial %d, nothing to synchronize\n", serial)
+		return nil
+	}
+	var todo []pypi.ProjectRef
+	for _, ref := range refs {
+		if !c.acceptProject(ref.Name) {
+			continue
+		}
+		if last, ok := state.Projects[pkg.Normalize(ref.Name)]; ok && ref.Serial != 0 && last == ref.Serial {
+			continue
+		}
+		todo = append(todo, ref)
+	}
+	fmt.Printf("%d project(s) to synchronize\n", len(todo))
+	var mu sync.Mutex
+	failures := 0
+	done := 0
+	err = pool.Run(ctx, len(todo), c.workers, func(i int) error {
+		ref := todo[i]
+		err := c.mirrorProject(ctx, client, ref.Name, constraints)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if !errors.Is(err, pypi.ErrNotFound) {
+				log.Printf("%s: %v", ref.Name, err)
+				failures++
+				return nil
+			}
+		}
+		state.Projects[pkg.Normalize(ref.Name)] = ref.Serial
+		if done++; done%100 == 0 {
+			return state.save(stateFile)
+		}
+		return nil
+	})
+	if err == nil && failures == 0 && serial != 0 {
+		state.LastSerial = serial
+	}
+	if saveErr := state.save(stateFile); err == nil {
+		err = saveErr
+	}
+	if err != nil {
+		return err
+	}
+	if failures > 0 {
+		return fmt.Errorf("failed to synchronize %d project(s)", failures)
+	}
+	return nil
+}
+
+func init() {
+	cmd := mirrorUpstreamCommand{}
+	flags := flag.NewFlagSet("mirror-upstream", flag.ExitOnError)
+	flags.StringVar(&cmd.downloadDir, "download-dir", ".", "download dir")
+	flags.StringVar(&cmd.indexURL, "index-url", pypi.DefaultIndexURL, "upstream simple index URL")
+	flags.StringVar(&cmd.proxy, "proxy", "", "proxy address in the form [user:passwd@]proxy.server:port")
+	flags.Var(&cmd.allow, "allow", "only mirror the projects whose normalized name matches this `pattern`, may be repeated")
+	flags.Var(&cmd.deny, "deny", "don't mirror the projects whose normalized name matches this `pattern`, may be repeated")
+	flags.StringVar(&cmd.constraints, "constraints", "", "only mirror the versions matching these version `constraints`")
+	flags.StringVar(&cmd.kind, "kind", "", "only mirror files of this `kind` (sdist or wheel)")
+	flags.Var(&cmd.platform, "platform", "only mirror the wheels whose platform tag matches this `pattern`, may be repeated")
+	flags.StringVar(&cmd.stateFile, "state-file", "", "file recording the upstream serials already mirrored (default to "+upstreamStateFilename+" in the download dir)")
+	flags.IntVar(&cmd.workers, "jobs", pkg.DefaultWorkers(), "number of projects processed concurrently")
+	cmd.flags = flags
+	RegisterCommand(&cmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMirrorUpstreamSkipsUnchangedIndex(t *testing.T) {
+	index := newTestIndex(t, []testDist{{"foo", "1.0", nil}})
+	handler := index.Config.Handler
+	index.Close()
+	serial := int64(42)
+	var projectRequests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/simple/" {
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("X-PyPI-Last-Serial", strconv.FormatInt(atomic.LoadInt64(&serial), 10))
+			w.Write([]byte(`<html><body><a href="/simple/foo/">foo</a></body></html>`))
+			return
+		}
+		if r.URL.Path == "/simple/foo/" {
+			atomic.AddInt32(&projectRequests, 1)
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "upstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := &mirrorUpstreamCommand{downloadDir: dir, indexURL: srv.URL + "/simple/", workers: 1}
+	for i, want := range []int32{1, 1, 2} {
+		if i == 2 {
+			atomic.StoreInt64(&serial, 43)
+		}
+		if err := c.Execute(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got := atomic.LoadInt32(&projectRequests); got != want {
+			t.Errorf("run %d: %d project request(s), want %d", i, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "foo-1.0-py3-none-any.whl")); err != nil {
+		t.Error(err)
+	}
+}
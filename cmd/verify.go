@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/montag451/go-pypi-mirror/pkg"
+)
+
+type verifyCommand struct {
+	flags       *flag.FlagSet
+	downloadDir string
+	repair      bool
+	workers     int
+}
+
+func (c *verifyCommand) FlagSet() *flag.FlagSet {
+	return c.flags
+}
+
+func repairFinding(f *pkg.Finding) error {
+	switch f.Problem {
+	case pkg.ProblemOrphanedMetadata:
+		return os.Remove(f.Path)
+	case pkg.ProblemMissingMetadata, pkg.ProblemHashMismatch, pkg.ProblemCorruptMetadata:
+		p, err := pkg.NewFromFile(f.Path)
+		if err != nil {
+			return err
+		}
+		if f.Problem != pkg.ProblemCorruptMetadata {
+			old, err := pkg.ReadMetadataFile(f.Path)
+			if err != nil {
+				return err
+			}
+			if old != nil {
+				p.Metadata.Source = old.Source
+			}
+		}
+		return pkg.WriteMetadataFile(p)
+	}
+	return fmt.Errorf("don't know how to repair %v", f.Problem)
+}
+
+func (c *verifyCommand) Execute(ctx context.Context) error {
+	findings, err := pkg.Verify(ctx, c.downloadDir, c.workers)
+	if err != nil {
+		return err
+	}
+	remaining := 0
+	for _, f := range findings {
+		switch f.Problem {
+		case pkg.ProblemHashMismatch:
+			fmt.Printf("%s: %v (expected sha256 %s, got %s)\n", f.Path, f.Problem, f.Expected, f.Actual)
+		case pkg.ProblemCorruptMetadata:
+			fmt.Printf("%s: %v (%v)\n", f.Path, f.Problem, f.Err)
+		default:
+			fmt.Printf("%s: %v\n", f.Path, f.Problem)
+		}
+		if !c.repair {
+			remaining++
+			continue
+		}
+		if err := repairFinding(f); err != nil {
+			log.Printf("failed to repair %s: %v", f.Path, err)
+			remaining++
+			continue
+		}
+		if f.Problem == pkg.ProblemOrphanedMetadata {
+			fmt.Printf("%s: removed\n", f.Path)
+		} else {
+			fmt.Printf("%s: repaired\n", f.Path)
+		}
+	}
+	if remaining > 0 {
+		return fmt.Errorf("%d problem(s) found", remaining)
+	}
+	return nil
+}
+
+func init() {
+	cmd := verifyCommand{}
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	flags.StringVar(&cmd.downloadDir, "download-dir", ".", "download dir")
+	flags.BoolVar(&cmd.repair, "repair", false, "regenerate stale or missing metadata files and remove orphaned ones")
+	flags.IntVar(&cmd.workers, "jobs", pkg.DefaultWorkers(), "number of packages processed concurrently")
+	cmd.flags = flags
+	RegisterCommand(&cmd)
+}
@@ -2,7 +2,4 @@ module github.com/montag451/go-pypi-mirror
 
 go 1.13
 
-require (
-	github.com/hashicorp/go-version v1.2.1
-	golang.org/x/text v0.3.3
-)
+require golang.org/x/text v0.3.3
@@ -0,0 +1,196 @@
+package pep440
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidSpecifier = errors.New("invalid specifier")
+
+var operators = []string{"===", "~=", "==", "!=", "<=", ">=", "<", ">"}
+
+type Specifier struct {
+	Op       string
+	Version  string
+	wildcard bool
+	v        *Version
+}
+
+func ParseSpecifier(s string) (*Specifier, error) {
+	s = strings.TrimSpace(s)
+	var op string
+	for _, o := range operators {
+		if strings.HasPrefix(s, o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		return nil, fmt.Errorf("%w %q: missing operator", ErrInvalidSpecifier, s)
+	}
+	spec := &Specifier{Op: op, Version: strings.TrimSpace(s[len(op):])}
+	if spec.Version == "" {
+		return nil, fmt.Errorf("%w %q: missing version", ErrInvalidSpecifier, s)
+	}
+	if op == "===" {
+		return spec, nil
+	}
+	raw := spec.Version
+	if strings.HasSuffix(raw, ".*") {
+		if op != "==" && op != "!=" {
+			return nil, fmt.Errorf("%w %q: wildcard not allowed with %q", ErrInvalidSpecifier, s, op)
+		}
+		spec.wildcard = true
+		raw = strings.TrimSuffix(raw, ".*")
+	}
+	v, err := Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrInvalidSpecifier, s, err)
+	}
+	if len(v.Local) > 0 && op != "==" && op != "!=" {
+		return nil, fmt.Errorf("%w %q: local version not allowed with %q", ErrInvalidSpecifier, s, op)
+	}
+	if spec.wildcard && (len(v.Local) > 0 || v.hasDev) {
+		return nil, fmt.Errorf("%w %q: invalid wildcard version", ErrInvalidSpecifier, s)
+	}
+	if op == "~=" && len(v.Release) < 2 {
+		return nil, fmt.Errorf("%w %q: at least two release segments required with %q", ErrInvalidSpecifier, s, op)
+	}
+	spec.v = v
+	return spec, nil
+}
+
+func (s *Specifier) String() string {
+	return s.Op + s.Version
+}
+
+func (s *Specifier) Prereleases() bool {
+	switch s.Op {
+	case "==", ">=", "<=", "~=", "===":
+		if s.v != nil {
+			return s.v.IsPrerelease()
+		}
+		if v, err := Parse(s.Version); err == nil {
+			return v.IsPrerelease()
+		}
+	}
+	return false
+}
+
+func (s *Specifier) matchPrefix(v *Version) bool {
+	prefix := s.v
+	if prefix.hasPre || prefix.hasPost {
+		return strings.HasPrefix(v.Public().String()+".", prefix.String()+".")
+	}
+	if v.Epoch != prefix.Epoch {
+		return false
+	}
+	for i, r := range prefix.Release {
+		var n int
+		if i < len(v.Release) {
+			n = v.Release[i]
+		}
+		if n != r {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Specifier) Contains(v *Version) bool {
+	switch s.Op {
+	case "===":
+		return strings.EqualFold(strings.TrimSpace(v.Original()), s.Version)
+	case "==":
+		if s.wildcard {
+			return s.matchPrefix(v)
+		}
+		if len(s.v.Local) == 0 {
+			v = v.Public()
+		}
+		return v.Equal(s.v)
+	case "!=":
+		if s.wildcard {
+			return !s.matchPrefix(v)
+		}
+		if len(s.v.Local) == 0 {
+			v = v.Public()
+		}
+		return !v.Equal(s.v)
+	case "~=":
+		release := s.v.Release[:len(s.v.Release)-1]
+		prefix := &Specifier{Op: "==", wildcard: true, v: &Version{Epoch: s.v.Epoch, Release: release}}
+		return v.Public().Compare(s.v) >= 0 && prefix.matchPrefix(v)
+	case "<=":
+		return v.Public().Compare(s.v) <= 0
+	case ">=":
+		return v.Public().Compare(s.v) >= 0
+	case "<":
+		if !v.LessThan(s.v) {
+			return false
+		}
+		if !s.v.IsPrerelease() && v.IsPrerelease() && v.Base().Equal(s.v.Base()) {
+			return false
+		}
+		return true
+	case ">":
+		if !v.GreaterThan(s.v) {
+			return false
+		}
+		if !s.v.IsPostrelease() && v.IsPostrelease() && v.Base().Equal(s.v.Base()) {
+			return false
+		}
+		if len(v.Local) > 0 && v.Base().Equal(s.v.Base()) {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+type SpecifierSet []*Specifier
+
+func ParseSpecifierSet(s string) (SpecifierSet, error) {
+	var set SpecifierSet
+	for _, clause := range strings.Split(s, ",") {
+		if strings.TrimSpace(clause) == "" {
+			continue
+		}
+		spec, err := ParseSpecifier(clause)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, spec)
+	}
+	return set, nil
+}
+
+func (s SpecifierSet) String() string {
+	specs := make([]string, len(s))
+	for i, spec := range s {
+		specs[i] = spec.String()
+	}
+	return strings.Join(specs, ",")
+}
+
+func (s SpecifierSet) Prereleases() bool {
+	for _, spec := range s {
+		if spec.Prereleases() {
+			return true
+		}
+	}
+	return false
+}
+
+func (s SpecifierSet) Contains(v *Version, prereleases bool) bool {
+	if !prereleases && !s.Prereleases() && v.IsPrerelease() {
+		return false
+	}
+	for _, spec := range s {
+		if !spec.Contains(v) {
+			return false
+		}
+	}
+	return true
+}
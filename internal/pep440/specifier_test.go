@@ -0,0 +1,185 @@
+package pep440
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSpecifierContains(t *testing.T) {
+	tests := []struct {
+		spec    string
+		match   []string
+		nomatch []string
+	}{
+		{
+			spec:    "==1.0",
+			match:   []string{"1.0", "1.0.0", "1.0+local", "0!1.0"},
+			nomatch: []string{"1.0.1", "1.0a1", "1.0.post1", "1.0.dev0", "1!1.0"},
+		},
+		{
+			spec:    "==1.0+abc",
+			match:   []string{"1.0+abc", "1.0+ABC"},
+			nomatch: []string{"1.0", "1.0+abd", "1.0+abc.1"},
+		},
+		{
+			spec:    "!=1.0",
+			match:   []string{"1.0.1", "1.0a1", "1.0.post1"},
+			nomatch: []string{"1.0", "1.0.0", "1.0+local"},
+		},
+		{
+			spec:    "!=1.0+abc",
+			match:   []string{"1.0", "1.0+abd"},
+			nomatch: []string{"1.0+abc"},
+		},
+		{
+			spec:    "==1.1.*",
+			match:   []string{"1.1", "1.1.0", "1.1.5", "1.1a1", "1.1.post1", "1.1.dev1", "1.1+local"},
+			nomatch: []string{"1.10", "1.2", "1.0", "2!1.1"},
+		},
+		{
+			spec:    "==1.0.0.*",
+			match:   []string{"1", "1.0", "1.0.0.1"},
+			nomatch: []string{"1.0.1"},
+		},
+		{
+			spec:    "==1.1.post1.*",
+			match:   []string{"1.1.post1", "1.1.post1.dev1"},
+			nomatch: []string{"1.1.post10", "1.1"},
+		},
+		{
+			spec:    "!=1.1.*",
+			match:   []string{"1.0", "1.10", "1.2"},
+			nomatch: []string{"1.1", "1.1.5", "1.1a1"},
+		},
+		{
+			spec:    "~=2.2",
+			match:   []string{"2.2", "2.3", "2.9.9", "2.2.post1"},
+			nomatch: []string{"2.1", "3.0", "2.2a1", "2.2.dev0"},
+		},
+		{
+			spec:    "~=1.4.5",
+			match:   []string{"1.4.5", "1.4.9", "1.4.5.post1"},
+			nomatch: []string{"1.4.4", "1.5.0", "2.0"},
+		},
+		{
+			spec:    "~=2.2.post3",
+			match:   []string{"2.2.post3", "2.3", "2.9"},
+			nomatch: []string{"2.2", "2.2.post2", "3.0"},
+		},
+		{
+			spec:    "~=1.4.5a4",
+			match:   []string{"1.4.5a4", "1.4.5", "1.4.9"},
+			nomatch: []string{"1.4.5a3", "1.5.0"},
+		},
+		{
+			spec:    "===1.0",
+			match:   []string{"1.0"},
+			nomatch: []string{"1.0.0", "1.0+local", "v1.0"},
+		},
+		{
+			spec:    "<=1.0",
+			match:   []string{"1.0", "1.0+local", "0.9", "1.0a1"},
+			nomatch: []string{"1.0.post1", "1.1"},
+		},
+		{
+			spec:    ">=1.0",
+			match:   []string{"1.0", "1.0+local", "1.0.post1", "2.0"},
+			nomatch: []string{"1.0a1", "1.0.dev0", "0.9"},
+		},
+		{
+			spec:    "<1.0",
+			match:   []string{"0.9", "0.9a1", "0.1"},
+			nomatch: []string{"1.0", "1.0a1", "1.0rc1", "1.0.dev0", "1.0+local", "1.0.post1"},
+		},
+		{
+			spec:    "<1.0rc1",
+			match:   []string{"1.0a1", "1.0b2", "1.0.dev0", "0.9"},
+			nomatch: []string{"1.0rc1", "1.0rc2", "1.0"},
+		},
+		{
+			spec:    ">1.0",
+			match:   []string{"1.0.1", "1.1a1", "2.0"},
+			nomatch: []string{"1.0", "1.0.post1", "1.0+local", "1.0.post1+local", "0.9"},
+		},
+		{
+			spec:    ">1.0.post1",
+			match:   []string{"1.0.post2", "1.1"},
+			nomatch: []string{"1.0.post1", "1.0.post1+local", "1.0"},
+		},
+		{
+			spec:    ">1.0a1",
+			match:   []string{"1.0a2", "1.0"},
+			nomatch: []string{"1.0a1", "1.0a1.post1"},
+		},
+	}
+	for _, test := range tests {
+		spec, err := ParseSpecifier(test.spec)
+		if err != nil {
+			t.Errorf("ParseSpecifier(%q) failed: %v", test.spec, err)
+			continue
+		}
+		for _, raw := range test.match {
+			if !spec.Contains(MustParse(raw)) {
+				t.Errorf("%q should contain %q", test.spec, raw)
+			}
+		}
+		for _, raw := range test.nomatch {
+			if spec.Contains(MustParse(raw)) {
+				t.Errorf("%q should not contain %q", test.spec, raw)
+			}
+		}
+	}
+}
+
+func TestParseSpecifierErrors(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"1.0",
+		"==",
+		"=1.0",
+		">=1.0.*",
+		"~=1.0.*",
+		"~=1",
+		">=1.0+local",
+		"<1.0+local",
+		"==1.0+local.*",
+		"==1.0.dev1.*",
+		"==foo",
+	} {
+		if _, err := ParseSpecifier(raw); !errors.Is(err, ErrInvalidSpecifier) {
+			t.Errorf("ParseSpecifier(%q) error = %v, want ErrInvalidSpecifier", raw, err)
+		}
+	}
+}
+
+func TestSpecifierSetContains(t *testing.T) {
+	tests := []struct {
+		specs       string
+		version     string
+		prereleases bool
+		want        bool
+	}{
+		{"", "1.0", false, true},
+		{"", "1.0a1", false, false},
+		{"", "1.0a1", true, true},
+		{">=1.0,<2.0", "1.5", false, true},
+		{">=1.0, <2.0", "2.0", false, false},
+		{">=1.0,<2.0", "1.5a1", false, false},
+		{">=1.0,<2.0", "1.5a1", true, true},
+		{">=1.0a1", "1.5a1", false, true},
+		{"==1.0rc1", "1.0rc1", false, true},
+		{">=1.0,!=1.5.*", "1.5.2", false, false},
+		{">=1.0,!=1.5.*", "1.6", false, true},
+		{"<2.0", "2.0.dev0", true, false},
+	}
+	for _, test := range tests {
+		specs, err := ParseSpecifierSet(test.specs)
+		if err != nil {
+			t.Errorf("ParseSpecifierSet(%q) failed: %v", test.specs, err)
+			continue
+		}
+		if got := specs.Contains(MustParse(test.version), test.prereleases); got != test.want {
+			t.Errorf("%q.Contains(%q, %v) = %v, want %v", test.specs, test.version, test.prereleases, got, test.want)
+		}
+	}
+}
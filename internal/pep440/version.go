@@ -0,0 +1,329 @@
+package pep440
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidVersion = errors.New("invalid version")
+
+var versionRegex = regexp.MustCompile(`(?i)^\s*v?` +
+	`(?:(?P<epoch>[0-9]+)!)?` +
+	`(?P<release>[0-9]+(?:\.[0-9]+)*)` +
+	`(?P<pre>[-_.]?(?P<pre_l>alpha|a|beta|b|preview|pre|c|rc)[-_.]?(?P<pre_n>[0-9]+)?)?` +
+	`(?P<post>(?:-(?P<post_n1>[0-9]+))|(?:[-_.]?(?P<post_l>post|rev|r)[-_.]?(?P<post_n2>[0-9]+)?))?` +
+	`(?P<dev>[-_.]?(?P<dev_l>dev)[-_.]?(?P<dev_n>[0-9]+)?)?` +
+	`(?:\+(?P<local>[a-z0-9]+(?:[-_.][a-z0-9]+)*))?` +
+	`\s*$`)
+
+var versionGroups = func() map[string]int {
+	groups := make(map[string]int)
+	for i, name := range versionRegex.SubexpNames() {
+		if name != "" {
+			groups[name] = i
+		}
+	}
+	return groups
+}()
+
+var preLabels = map[string]string{
+	"a":       "a",
+	"alpha":   "a",
+	"b":       "b",
+	"beta":    "b",
+	"c":       "rc",
+	"rc":      "rc",
+	"pre":     "rc",
+	"preview": "rc",
+}
+
+var preOrder = map[string]int{
+	"a":  0,
+	"b":  1,
+	"rc": 2,
+}
+
+type Version struct {
+	Epoch    int
+	Release  []int
+	PreLabel string
+	Pre      int
+	Post     int
+	Dev      int
+	Local    []string
+	hasPre   bool
+	hasPost  bool
+	hasDev   bool
+	original string
+}
+
+func Parse(s string) (*Version, error) {
+	m := versionRegex.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("%w %q", ErrInvalidVersion, s)
+	}
+	group := func(name string) string {
+		return m[versionGroups[name]]
+	}
+	atoi := func(s string) (int, error) {
+		if s == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("%w %q: %v", ErrInvalidVersion, s, err)
+		}
+		return n, nil
+	}
+	v := &Version{original: s}
+	var err error
+	if v.Epoch, err = atoi(group("epoch")); err != nil {
+		return nil, err
+	}
+	for _, r := range strings.Split(group("release"), ".") {
+		n, err := atoi(r)
+		if err != nil {
+			return nil, err
+		}
+		v.Release = append(v.Release, n)
+	}
+	if group("pre") != "" {
+		v.hasPre = true
+		v.PreLabel = preLabels[strings.ToLower(group("pre_l"))]
+		if v.Pre, err = atoi(group("pre_n")); err != nil {
+			return nil, err
+		}
+	}
+	if group("post") != "" {
+		v.hasPost = true
+		n := group("post_n1")
+		if n == "" {
+			n = group("post_n2")
+		}
+		if v.Post, err = atoi(n); err != nil {
+			return nil, err
+		}
+	}
+	if group("dev") != "" {
+		v.hasDev = true
+		if v.Dev, err = atoi(group("dev_n")); err != nil {
+			return nil, err
+		}
+	}
+	if local := group("local"); local != "" {
+		v.Local = strings.FieldsFunc(strings.ToLower(local), func(r rune) bool {
+			return r == '-' || r == '_' || r == '.'
+		})
+	}
+	return v, nil
+}
+
+func MustParse(s string) *Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (v *Version) Original() string {
+	return v.original
+}
+
+func (v *Version) HasPre() bool {
+	return v.hasPre
+}
+
+func (v *Version) HasPost() bool {
+	return v.hasPost
+}
+
+func (v *Version) HasDev() bool {
+	return v.hasDev
+}
+
+func (v *Version) IsPrerelease() bool {
+	return v.hasPre || v.hasDev
+}
+
+func (v *Version) IsPostrelease() bool {
+	return v.hasPost
+}
+
+func (v *Version) Public() *Version {
+	p := *v
+	p.Local = nil
+	p.original = p.String()
+	return &p
+}
+
+func (v *Version) Base() *Version {
+	return &Version{
+		Epoch:    v.Epoch,
+		Release:  v.Release,
+		original: v.baseString(),
+	}
+}
+
+func (v *Version) baseString() string {
+	var b strings.Builder
+	if v.Epoch != 0 {
+		fmt.Fprintf(&b, "%d!", v.Epoch)
+	}
+	for i, r := range v.Release {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(strconv.Itoa(r))
+	}
+	return b.String()
+}
+
+func (v *Version) String() string {
+	var b strings.Builder
+	b.WriteString(v.baseString())
+	if v.hasPre {
+		fmt.Fprintf(&b, "%s%d", v.PreLabel, v.Pre)
+	}
+	if v.hasPost {
+		fmt.Fprintf(&b, ".post%d", v.Post)
+	}
+	if v.hasDev {
+		fmt.Fprintf(&b, ".dev%d", v.Dev)
+	}
+	if len(v.Local) > 0 {
+		b.WriteByte('+')
+		b.WriteString(strings.Join(v.Local, "."))
+	}
+	return b.String()
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareRelease(a, b []int) int {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if c := compareInts(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func (v *Version) preKey() (int, int) {
+	switch {
+	case !v.hasPre && !v.hasPost && v.hasDev:
+		return -1, 0
+	case !v.hasPre:
+		return len(preOrder), 0
+	}
+	return preOrder[v.PreLabel], v.Pre
+}
+
+func compareLocal(a, b []string) int {
+	if len(a) == 0 || len(b) == 0 {
+		return compareInts(len(a), len(b))
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, errX := strconv.Atoi(a[i])
+		y, errY := strconv.Atoi(b[i])
+		switch {
+		case errX == nil && errY == nil:
+			if c := compareInts(x, y); c != 0 {
+				return c
+			}
+		case errX == nil:
+			return 1
+		case errY == nil:
+			return -1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInts(len(a), len(b))
+}
+
+func (v *Version) Compare(o *Version) int {
+	if c := compareInts(v.Epoch, o.Epoch); c != 0 {
+		return c
+	}
+	if c := compareRelease(v.Release, o.Release); c != 0 {
+		return c
+	}
+	vl, vn := v.preKey()
+	ol, on := o.preKey()
+	if c := compareInts(vl, ol); c != 0 {
+		return c
+	}
+	if c := compareInts(vn, on); c != 0 {
+		return c
+	}
+	if v.hasPost != o.hasPost {
+		if v.hasPost {
+			return 1
+		}
+		return -1
+	}
+	if c := compareInts(v.Post, o.Post); c != 0 {
+		return c
+	}
+	if v.hasDev != o.hasDev {
+		if v.hasDev {
+			return -1
+		}
+		return 1
+	}
+	if c := compareInts(v.Dev, o.Dev); c != 0 {
+		return c
+	}
+	return compareLocal(v.Local, o.Local)
+}
+
+func (v *Version) Equal(o *Version) bool {
+	return v.Compare(o) == 0
+}
+
+func (v *Version) LessThan(o *Version) bool {
+	return v.Compare(o) < 0
+}
+
+func (v *Version) GreaterThan(o *Version) bool {
+	return v.Compare(o) > 0
+}
+
+type Collection []*Version
+
+func (c Collection) Len() int {
+	return len(c)
+}
+
+func (c Collection) Less(i, j int) bool {
+	return c[i].LessThan(c[j])
+}
+
+func (c Collection) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
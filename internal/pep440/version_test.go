@@ -0,0 +1,157 @@
+package pep440
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"1.0", "1.0"},
+		{"v1.0", "1.0"},
+		{" 1.0\n", "1.0"},
+		{"1!2.0", "1!2.0"},
+		{"0!1.0", "1.0"},
+		{"1.0a", "1.0a0"},
+		{"1.0-alpha.1", "1.0a1"},
+		{"1.0.BETA2", "1.0b2"},
+		{"1.0c3", "1.0rc3"},
+		{"1.0pre4", "1.0rc4"},
+		{"1.0preview5", "1.0rc5"},
+		{"1.0-1", "1.0.post1"},
+		{"1.0.post", "1.0.post0"},
+		{"1.0_rev2", "1.0.post2"},
+		{"1.0r3", "1.0.post3"},
+		{"1.0-dev", "1.0.dev0"},
+		{"1.0.dev-1", "1.0.dev1"},
+		{"1.0a1.post2.dev3", "1.0a1.post2.dev3"},
+		{"01.002", "1.2"},
+		{"1.0+Ubuntu-1_A", "1.0+ubuntu.1.a"},
+	}
+	for _, test := range tests {
+		v, err := Parse(test.raw)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", test.raw, err)
+			continue
+		}
+		if got := v.String(); got != test.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", test.raw, got, test.want)
+		}
+		if v.Original() != test.raw {
+			t.Errorf("Parse(%q).Original() = %q", test.raw, v.Original())
+		}
+	}
+	for _, raw := range []string{"", "foo", "1.0+", "1.0+a..b", "1..0", "1.0-", "1.0.dev1a", "1.0a1b1"} {
+		if _, err := Parse(raw); !errors.Is(err, ErrInvalidVersion) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidVersion", raw, err)
+		}
+	}
+}
+
+func TestVersionOrder(t *testing.T) {
+	ordered := []string{
+		"1.0.dev456",
+		"1.0a1",
+		"1.0a2.dev456",
+		"1.0a12.dev456",
+		"1.0a12",
+		"1.0b1.dev456",
+		"1.0b2",
+		"1.0b2.post345.dev456",
+		"1.0b2.post345",
+		"1.0rc1.dev456",
+		"1.0rc1",
+		"1.0",
+		"1.0+abc.5",
+		"1.0+abc.7",
+		"1.0+5",
+		"1.0+5.1",
+		"1.0.post456.dev34",
+		"1.0.post456",
+		"1.0.15",
+		"1.1.dev1",
+		"1.10",
+		"2.0a1",
+		"2.0",
+		"1!0.1",
+		"1!1.0.dev0",
+		"2!0.0.1",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			v1, v2 := MustParse(ordered[i]), MustParse(ordered[j])
+			want := compareInts(i, j)
+			if got := v1.Compare(v2); got != want {
+				t.Errorf("Compare(%q, %q) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+	shuffled := make(Collection, len(ordered))
+	for i, j := range rand.New(rand.NewSource(1)).Perm(len(ordered)) {
+		shuffled[i] = MustParse(ordered[j])
+	}
+	sort.Sort(shuffled)
+	for i, v := range shuffled {
+		if v.Original() != ordered[i] {
+			t.Errorf("sorted[%d] = %q, want %q", i, v.Original(), ordered[i])
+		}
+	}
+}
+
+func TestVersionEqual(t *testing.T) {
+	tests := []struct {
+		v1, v2 string
+		equal  bool
+	}{
+		{"1.0", "1.0.0", true},
+		{"1.0", "1!1.0", false},
+		{"0!1.0", "1.0", true},
+		{"1.0a1", "1.0alpha1", true},
+		{"1.0.post0", "1.0", false},
+		{"1.0.dev0", "1.0", false},
+		{"1.0+ABC", "1.0+abc", true},
+		{"1.0+1", "1.0+01", true},
+		{"1.0+abc", "1.0", false},
+	}
+	for _, test := range tests {
+		if got := MustParse(test.v1).Equal(MustParse(test.v2)); got != test.equal {
+			t.Errorf("Equal(%q, %q) = %v, want %v", test.v1, test.v2, got, test.equal)
+		}
+	}
+}
+
+func TestVersionPredicates(t *testing.T) {
+	tests := []struct {
+		raw        string
+		pre        bool
+		post       bool
+		dev        bool
+		prerelease bool
+		base       string
+	}{
+		{"1.0", false, false, false, false, "1.0"},
+		{"1.0a1", true, false, false, true, "1.0"},
+		{"1.0.dev1", false, false, true, true, "1.0"},
+		{"1.0.post1", false, true, false, false, "1.0"},
+		{"1.0.post1.dev1", false, true, true, true, "1.0"},
+		{"2!1.0rc1+local", true, false, false, true, "2!1.0"},
+	}
+	for _, test := range tests {
+		v := MustParse(test.raw)
+		if v.HasPre() != test.pre || v.HasPost() != test.post || v.HasDev() != test.dev {
+			t.Errorf("%q: HasPre/HasPost/HasDev = %v/%v/%v, want %v/%v/%v",
+				test.raw, v.HasPre(), v.HasPost(), v.HasDev(), test.pre, test.post, test.dev)
+		}
+		if got := v.IsPrerelease(); got != test.prerelease {
+			t.Errorf("%q: IsPrerelease() = %v", test.raw, got)
+		}
+		if got := v.Base().String(); got != test.base {
+			t.Errorf("%q: Base() = %q, want %q", test.raw, got, test.base)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package pool
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+func Run(ctx context.Context, n int, workers int, fn func(int) error) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	jobs := make(chan int)
+	errCh := make(chan error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := fn(i); err != nil {
+					errCh <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case jobs <- i:
+		case <-workerCtx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errCh)
+	if err := <-errCh; err != nil {
+		return err
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,538 @@
+package pypi
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/montag451/go-pypi-mirror/pkg"
+)
+
+const DefaultIndexURL = "https://pypi.org/simple/"
+
+const (
+	simpleJSONContentType = "application/vnd.pypi.simple.v1+json"
+	simpleHTMLContentType = "application/vnd.pypi.simple.v1+html"
+	acceptHeader          = simpleJSONContentType + ", " + simpleHTMLContentType + ";q=0.2, text/html;q=0.1, application/json;q=0.05"
+)
+
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrInvalidFilename = errors.New("invalid filename")
+)
+
+type File struct {
+	Filename       string
+	URL            string
+	Version        string
+	Hashes         map[string]string
+	RequiresPython string
+	Yanked         bool
+	YankedReason   string
+	UploadTime     time.Time
+	Size           int64
+	PackageType    string
+	PythonVersion  string
+}
+
+func (f *File) Kind() pkg.Kind {
+	return pkg.KindOf(f.Filename)
+}
+
+type Project struct {
+	Name     string
+	URL      string
+	Versions []string
+	Files    []*File
+}
+
+type Client struct {
+	HTTPClient *http.Client
+	URL        string
+	Username   string
+	Password   string
+	Token      string
+	tmpl       *template.Template
+	host       string
+}
+
+func NewClient(indexURL string, proxy string) (*Client, error) {
+	if indexURL == "" {
+		indexURL = DefaultIndexURL
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	c := &Client{
+		HTTPClient: &http.Client{Transport: transport},
+		URL:        indexURL,
+	}
+	base := indexURL
+	if idx := strings.Index(indexURL, "{{"); idx != -1 {
+		t, err := template.New("").Parse(indexURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL template %q: %w", indexURL, err)
+		}
+		c.tmpl = t
+		base = indexURL[:idx]
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid index URL %q: %w", indexURL, err)
+	}
+	c.host = u.Host
+	if proxy != "" {
+		if !strings.Contains(proxy, "://") {
+			proxy = "http://" + proxy
+		}
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %w", proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	return c, nil
+}
+
+func (c *Client) LoadCABundle(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificate found in %q", path)
+	}
+	transport, ok := c.HTTPClient.Transport.(*http.Transport)
+	if !ok {
+		return errors.New("unsupported HTTP transport")
+	}
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return nil
+}
+
+func (c *Client) newRequest(ctx context.Context, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %q: %w", rawURL, err)
+	}
+	if req.URL.Host != c.host {
+		return req, nil
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	return req, nil
+}
+
+func (c *Client) ProjectURL(name string) (string, error) {
+	if c.tmpl == nil {
+		return strings.TrimSuffix(c.URL, "/") + "/" + pkg.Normalize(name) + "/", nil
+	}
+	var u strings.Builder
+	if err := c.tmpl.Execute(&u, name); err != nil {
+		return "", fmt.Errorf("failed to execute URL template %q: %w", c.URL, err)
+	}
+	return u.String(), nil
+}
+
+func (c *Client) get(ctx context.Context, rawURL string, accept string) (*http.Response, error) {
+	req, err := c.newRequest(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %q", ErrNotFound, rawURL)
+		}
+		return nil, fmt.Errorf("failed to get %q, HTTP code: %v", rawURL, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (c *Client) Project(ctx context.Context, name string) (*Project, error) {
+	projectURL, err := c.ProjectURL(name)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.get(ctx, projectURL, acceptHeader)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	base := resp.Request.URL
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	var project *Project
+	switch contentType {
+	case simpleJSONContentType:
+		project, err = parseSimpleJSON(resp.Body, base)
+	case "application/json":
+		project, err = parseJSONAPI(resp.Body, base)
+	default:
+		project, err = parseSimpleHTML(resp.Body, base)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response of %q: %w", projectURL, err)
+	}
+	if project.Name == "" {
+		project.Name = name
+	}
+	project.URL = projectURL
+	versions := make(map[string]bool, len(project.Versions))
+	for _, v := range project.Versions {
+		versions[v] = true
+	}
+	for _, f := range project.Files {
+		if f.Version == "" {
+			if f.Version, err = pkg.VersionFromFilename(f.Filename, project.Name); err != nil {
+				continue
+			}
+		}
+		if !versions[f.Version] {
+			versions[f.Version] = true
+			project.Versions = append(project.Versions, f.Version)
+		}
+	}
+	return project, nil
+}
+
+type ProjectRef struct {
+	Name   string
+	Serial int64
+}
+
+func (c *Client) Projects(ctx context.Context) ([]ProjectRef, int64, error) {
+	if c.tmpl != nil {
+		return nil, 0, fmt.Errorf("unable to list the projects of URL template %q", c.URL)
+	}
+	resp, err := c.get(ctx, c.URL, acceptHeader)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	serial, _ := strconv.ParseInt(resp.Header.Get("X-PyPI-Last-Serial"), 10, 64)
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if contentType == simpleJSONContentType {
+		var root struct {
+			Meta struct {
+				LastSerial int64 `json:"_last-serial"`
+			} `json:"meta"`
+			Projects []struct {
+				Name       string `json:"name"`
+				LastSerial int64  `json:"_last-serial"`
+			} `json:"projects"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&root); err != nil {
+			return nil, 0, fmt.Errorf("failed to parse response of %q: %w", c.URL, err)
+		}
+		if root.Meta.LastSerial != 0 {
+			serial = root.Meta.LastSerial
+		}
+		refs := make([]ProjectRef, 0, len(root.Projects))
+		for _, p := range root.Projects {
+			refs = append(refs, ProjectRef{p.Name, p.LastSerial})
+		}
+		return refs, serial, nil
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	var refs []ProjectRef
+	for _, a := range parseAnchors(string(data)) {
+		if name := strings.TrimSpace(a.text); name != "" {
+			refs = append(refs, ProjectRef{Name: name})
+		}
+	}
+	return refs, serial, nil
+}
+
+func resolveURL(base *url.URL, ref string) (string, error) {
+	u, err := base.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func parseYanked(raw json.RawMessage) (bool, string) {
+	var yanked bool
+	if err := json.Unmarshal(raw, &yanked); err == nil {
+		return yanked, ""
+	}
+	var reason string
+	if err := json.Unmarshal(raw, &reason); err == nil {
+		return true, reason
+	}
+	return false, ""
+}
+
+func parseSimpleJSON(r io.Reader, base *url.URL) (*Project, error) {
+	var page struct {
+		Name     string   `json:"name"`
+		Versions []string `json:"versions"`
+		Files    []struct {
+			Filename       string            `json:"filename"`
+			URL            string            `json:"url"`
+			Hashes         map[string]string `json:"hashes"`
+			RequiresPython string            `json:"requires-python"`
+			Yanked         json.RawMessage   `json:"yanked"`
+			UploadTime     string            `json:"upload-time"`
+			Size           int64             `json:"size"`
+		} `json:"files"`
+	}
+	if err := json.NewDecoder(r).Decode(&page); err != nil {
+		return nil, err
+	}
+	project := &Project{Name: page.Name, Versions: page.Versions}
+	for _, f := range page.Files {
+		u, err := resolveURL(base, f.URL)
+		if err != nil {
+			return nil, err
+		}
+		yanked, reason := parseYanked(f.Yanked)
+		project.Files = append(project.Files, &File{
+			Filename:       f.Filename,
+			URL:            u,
+			Hashes:         f.Hashes,
+			RequiresPython: f.RequiresPython,
+			Yanked:         yanked,
+			YankedReason:   reason,
+			UploadTime:     parseTime(f.UploadTime),
+			Size:           f.Size,
+		})
+	}
+	return project, nil
+}
+
+func parseJSONAPI(r io.Reader, base *url.URL) (*Project, error) {
+	var page struct {
+		Info struct {
+			Name string `json:"name"`
+		} `json:"info"`
+		Releases map[string][]struct {
+			Filename       string            `json:"filename"`
+			URL            string            `json:"url"`
+			Digests        map[string]string `json:"digests"`
+			RequiresPython string            `json:"requires_python"`
+			Yanked         bool              `json:"yanked"`
+			YankedReason   string            `json:"yanked_reason"`
+			UploadTime     string            `json:"upload_time_iso_8601"`
+			Size           int64             `json:"size"`
+			PackageType    string            `json:"packagetype"`
+			PythonVersion  string            `json:"python_version"`
+		} `json:"releases"`
+	}
+	if err := json.NewDecoder(r).Decode(&page); err != nil {
+		return nil, err
+	}
+	if page.Releases == nil {
+		return nil, fmt.Errorf("missing or invalid key: %q", "releases")
+	}
+	project := &Project{Name: page.Info.Name}
+	for version, files := range page.Releases {
+		project.Versions = append(project.Versions, version)
+		for _, f := range files {
+			u, err := resolveURL(base, f.URL)
+			if err != nil {
+				return nil, err
+			}
+			project.Files = append(project.Files, &File{
+				Filename:       f.Filename,
+				URL:            u,
+				Version:        version,
+				Hashes:         f.Digests,
+				RequiresPython: f.RequiresPython,
+				Yanked:         f.Yanked,
+				YankedReason:   f.YankedReason,
+				UploadTime:     parseTime(f.UploadTime),
+				Size:           f.Size,
+				PackageType:    f.PackageType,
+				PythonVersion:  f.PythonVersion,
+			})
+		}
+	}
+	return project, nil
+}
+
+func parseSimpleHTML(r io.Reader, base *url.URL) (*Project, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	project := &Project{}
+	for _, a := range parseAnchors(string(data)) {
+		href, ok := a.attrs["href"]
+		if !ok {
+			continue
+		}
+		u, err := base.Parse(href)
+		if err != nil {
+			return nil, err
+		}
+		f := &File{
+			Filename:       strings.TrimSpace(a.text),
+			RequiresPython: a.attrs["data-requires-python"],
+			Hashes:         make(map[string]string),
+		}
+		if f.Filename == "" {
+			f.Filename = filepath.Base(u.Path)
+		}
+		if kv := strings.SplitN(u.Fragment, "=", 2); len(kv) == 2 {
+			f.Hashes[kv[0]] = kv[1]
+		}
+		u.Fragment = ""
+		f.URL = u.String()
+		if reason, ok := a.attrs["data-yanked"]; ok {
+			f.Yanked = true
+			f.YankedReason = reason
+		}
+		project.Files = append(project.Files, f)
+	}
+	return project, nil
+}
+
+func Verify(path string, hashes map[string]string) error {
+	expected, ok := hashes["sha256"]
+	if !ok {
+		return nil
+	}
+	actual, err := pkg.HashFile(path)
+	if err != nil {
+		return err
+	}
+	if actual != strings.ToLower(expected) {
+		return fmt.Errorf("%w for %s: expected sha256 %s, got %s", pkg.ErrHashMismatch, path, expected, actual)
+	}
+	return nil
+}
+
+func (c *Client) fetchPart(ctx context.Context, rawURL string, part string) error {
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+	req, err := c.newRequest(ctx, rawURL)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	flags := os.O_WRONLY | os.O_CREATE
+	switch resp.StatusCode {
+	case http.StatusOK:
+		flags |= os.O_TRUNC
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusRequestedRangeNotSatisfiable:
+		if err := os.Remove(part); err != nil {
+			return err
+		}
+		return c.fetchPart(ctx, rawURL, part)
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %q", ErrNotFound, rawURL)
+	default:
+		return fmt.Errorf("failed to get %q, HTTP code: %v", rawURL, resp.StatusCode)
+	}
+	f, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func checkFilename(f *File) error {
+	name := f.Filename
+	if name == "" || name == "." || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return fmt.Errorf("%w %q", ErrInvalidFilename, name)
+	}
+	u, err := url.Parse(f.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", f.URL, err)
+	}
+	if base := path.Base(u.Path); base != name {
+		return fmt.Errorf("%w %q: does not match URL %q", ErrInvalidFilename, name, f.URL)
+	}
+	return nil
+}
+
+func (c *Client) Download(ctx context.Context, f *File, dir string) (string, bool, error) {
+	if err := checkFilename(f); err != nil {
+		return "", false, err
+	}
+	dest := filepath.Join(dir, f.Filename)
+	if rel, err := filepath.Rel(dir, dest); err != nil || rel != f.Filename {
+		return "", false, fmt.Errorf("%w %q: outside of %q", ErrInvalidFilename, f.Filename, dir)
+	}
+	if _, err := os.Stat(dest); err == nil {
+		if err := Verify(dest, f.Hashes); err == nil {
+			return dest, false, nil
+		}
+	}
+	part := filepath.Join(dir, "."+f.Filename+".part")
+	if err := c.fetchPart(ctx, f.URL, part); err != nil {
+		return "", false, err
+	}
+	if err := Verify(part, f.Hashes); err != nil {
+		os.Remove(part)
+		return "", false, fmt.Errorf("failed to download %q: %w", f.URL, err)
+	}
+	if err := os.Rename(part, dest); err != nil {
+		return "", false, err
+	}
+	return dest, true, nil
+}
+
+func (c *Client) Source(f *File, downloadTime time.Time) *pkg.Source {
+	s := &pkg.Source{
+		IndexURL:     c.URL,
+		URL:          f.URL,
+		Digests:      f.Hashes,
+		DownloadTime: downloadTime.UTC().Format(time.RFC3339),
+	}
+	if !f.UploadTime.IsZero() {
+		s.UploadTime = f.UploadTime.UTC().Format(time.RFC3339)
+	}
+	return s
+}
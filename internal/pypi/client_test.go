@@ -0,0 +1,321 @@
+package pypi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/montag451/go-pypi-mirror/pkg"
+)
+
+func sha256Hex(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+func newTestClient(t *testing.T, indexURL string) *Client {
+	t.Helper()
+	c, err := NewClient(indexURL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "pypi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func filenames(p *Project) []string {
+	var names []string
+	for _, f := range p.Files {
+		names = append(names, f.Filename)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestProjectSimpleJSON(t *testing.T) {
+	var accept string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/simple/foo-bar/" {
+			http.NotFound(w, r)
+			return
+		}
+		accept = r.Header.Get("Accept")
+		w.Header().Set("Content-Type", simpleJSONContentType)
+		fmt.Fprint(w, `{
+  "meta": {"api-version": "1.1"},
+  "name": "foo-bar",
+  "versions": ["1.0", "2.0"],
+  "files": [
+    {"filename": "foo_bar-1.0-py3-none-any.whl", "url": "../../files/foo_bar-1.0-py3-none-any.whl",
+     "hashes": {"sha256": "aaaa"}, "requires-python": ">=3.6", "yanked": false,
+     "upload-time": "2020-01-02T03:04:05.000000Z", "size": 42},
+    {"filename": "foo-bar-2.0.tar.gz", "url": "https://files.example.com/foo-bar-2.0.tar.gz",
+     "hashes": {"sha256": "bbbb"}, "yanked": "broken"}
+  ]
+}`)
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv.URL+"/simple/")
+	project, err := c.Project(context.Background(), "Foo_Bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(accept, simpleJSONContentType) {
+		t.Errorf("Accept = %q, want JSON preferred", accept)
+	}
+	if project.Name != "foo-bar" {
+		t.Errorf("Name = %q, want %q", project.Name, "foo-bar")
+	}
+	if got := strings.Join(project.Versions, ","); got != "1.0,2.0" {
+		t.Errorf("Versions = %q, want %q", got, "1.0,2.0")
+	}
+	if len(project.Files) != 2 {
+		t.Fatalf("got %d files, want 2", len(project.Files))
+	}
+	whl, sdist := project.Files[0], project.Files[1]
+	if want := srv.URL + "/files/foo_bar-1.0-py3-none-any.whl"; whl.URL != want {
+		t.Errorf("URL = %q, want %q", whl.URL, want)
+	}
+	if whl.Version != "1.0" || whl.RequiresPython != ">=3.6" || whl.Size != 42 || whl.Hashes["sha256"] != "aaaa" {
+		t.Errorf("unexpected wheel %+v", whl)
+	}
+	if want := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC); !whl.UploadTime.Equal(want) {
+		t.Errorf("UploadTime = %v, want %v", whl.UploadTime, want)
+	}
+	if whl.Yanked {
+		t.Errorf("wheel should not be yanked")
+	}
+	if sdist.Version != "2.0" || !sdist.Yanked || sdist.YankedReason != "broken" {
+		t.Errorf("unexpected sdist %+v", sdist)
+	}
+}
+
+func TestProjectSimpleHTML(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<!DOCTYPE html>
+<html><body>
+<a href="/files/foo-1.0.tar.gz#sha256=cccc" data-requires-python="&gt;=3.7">foo-1.0.tar.gz</a><br/>
+<a href="/files/foo-1.1-py3-none-any.whl" data-yanked="">foo-1.1-py3-none-any.whl</a><br/>
+<a href="/files/foo-1.2.zip"></a>
+</body></html>`)
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv.URL+"/simple")
+	project, err := c.Project(context.Background(), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Name != "foo" {
+		t.Errorf("Name = %q, want %q", project.Name, "foo")
+	}
+	want := []string{"foo-1.0.tar.gz", "foo-1.1-py3-none-any.whl", "foo-1.2.zip"}
+	if got := filenames(project); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+	sdist, whl := project.Files[0], project.Files[1]
+	if sdist.URL != srv.URL+"/files/foo-1.0.tar.gz" {
+		t.Errorf("URL = %q, fragment should be stripped", sdist.URL)
+	}
+	if sdist.Hashes["sha256"] != "cccc" || sdist.RequiresPython != ">=3.7" || sdist.Version != "1.0" {
+		t.Errorf("unexpected sdist %+v", sdist)
+	}
+	if !whl.Yanked || whl.Version != "1.1" {
+		t.Errorf("unexpected wheel %+v", whl)
+	}
+	if got := strings.Join(project.Versions, ","); got != "1.0,1.1,1.2" {
+		t.Errorf("Versions = %q, want %q", got, "1.0,1.1,1.2")
+	}
+}
+
+func TestProjectJSONAPI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pypi/foo/json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+  "info": {"name": "Foo"},
+  "releases": {
+    "1.0": [{"filename": "foo-1.0.tar.gz", "url": "https://files.example.com/foo-1.0.tar.gz",
+             "digests": {"sha256": "dddd"}, "requires_python": ">=3.5", "yanked": true,
+             "yanked_reason": "bad", "upload_time_iso_8601": "2021-05-06T07:08:09.123456Z",
+             "size": 10, "packagetype": "sdist", "python_version": "source"}],
+    "2.0": []
+  }
+}`)
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv.URL+"/pypi/{{ . }}/json")
+	project, err := c.Project(context.Background(), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Name != "Foo" {
+		t.Errorf("Name = %q, want %q", project.Name, "Foo")
+	}
+	versions := append([]string(nil), project.Versions...)
+	sort.Strings(versions)
+	if got := strings.Join(versions, ","); got != "1.0,2.0" {
+		t.Errorf("Versions = %q, want %q", got, "1.0,2.0")
+	}
+	if len(project.Files) != 1 {
+		t.Fatalf("got %d files, want 1", len(project.Files))
+	}
+	f := project.Files[0]
+	if f.Version != "1.0" || f.Hashes["sha256"] != "dddd" || f.RequiresPython != ">=3.5" ||
+		!f.Yanked || f.YankedReason != "bad" || f.Size != 10 || f.PackageType != "sdist" {
+		t.Errorf("unexpected file %+v", f)
+	}
+	if _, err := c.Project(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want ErrNotFound", err)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	content := []byte("archive content")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "foo-1.0.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv.URL+"/simple/")
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	f := &File{
+		Filename: "foo-1.0.tar.gz",
+		URL:      srv.URL + "/files/foo-1.0.tar.gz",
+		Hashes:   map[string]string{"sha256": sha256Hex(content)},
+	}
+	dest, downloaded, err := c.Download(context.Background(), f, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !downloaded || dest != filepath.Join(dir, f.Filename) {
+		t.Errorf("Download() = %q, %v", dest, downloaded)
+	}
+	if data, err := ioutil.ReadFile(dest); err != nil || !bytes.Equal(data, content) {
+		t.Errorf("unexpected content %q (%v)", data, err)
+	}
+	if _, downloaded, err := c.Download(context.Background(), f, dir); err != nil || downloaded {
+		t.Errorf("second Download() = %v, %v, want no download", downloaded, err)
+	}
+}
+
+func TestDownloadHashMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "tampered content")
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv.URL+"/simple/")
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	f := &File{
+		Filename: "foo-1.0.tar.gz",
+		URL:      srv.URL + "/files/foo-1.0.tar.gz",
+		Hashes:   map[string]string{"sha256": sha256Hex([]byte("genuine content"))},
+	}
+	_, _, err := c.Download(context.Background(), f, dir)
+	if !errors.Is(err, pkg.ErrHashMismatch) {
+		t.Fatalf("got error %v, want ErrHashMismatch", err)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("download dir should be empty, got %d entries", len(entries))
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	var mu sync.Mutex
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		http.ServeContent(w, r, "foo-1.0.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv.URL+"/simple/")
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	f := &File{
+		Filename: "foo-1.0.tar.gz",
+		URL:      srv.URL + "/files/foo-1.0.tar.gz",
+		Hashes:   map[string]string{"sha256": sha256Hex(content)},
+	}
+	part := filepath.Join(dir, ".foo-1.0.tar.gz.part")
+	if err := ioutil.WriteFile(part, content[:10], 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest, downloaded, err := c.Download(context.Background(), f, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !downloaded {
+		t.Errorf("file should have been downloaded")
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=10-" {
+		t.Errorf("Range headers = %q, want [\"bytes=10-\"]", ranges)
+	}
+	if data, err := ioutil.ReadFile(dest); err != nil || !bytes.Equal(data, content) {
+		t.Errorf("unexpected content %q (%v)", data, err)
+	}
+	if _, err := os.Stat(part); !os.IsNotExist(err) {
+		t.Errorf("partial file should have been renamed")
+	}
+}
+
+func TestDownloadInvalidFilename(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, "evil")
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv.URL+"/simple/")
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		filename string
+		url      string
+	}{
+		{"x/../../evil-1.0.tar.gz", "/files/evil-1.0.tar.gz"},
+		{"../evil-1.0.tar.gz", "/files/evil-1.0.tar.gz"},
+		{`..\evil-1.0.tar.gz`, "/files/evil-1.0.tar.gz"},
+		{"evil..1.0.tar.gz", "/files/evil..1.0.tar.gz"},
+		{"", "/files/"},
+		{"foo-1.0.tar.gz", "/files/evil-1.0.tar.gz"},
+	}
+	for _, test := range tests {
+		f := &File{Filename: test.filename, URL: srv.URL + test.url}
+		if _, _, err := c.Download(context.Background(), f, dir); !errors.Is(err, ErrInvalidFilename) {
+			t.Errorf("Download(%q) error = %v, want ErrInvalidFilename", test.filename, err)
+		}
+	}
+	if requests != 0 {
+		t.Errorf("got %d requests, want none", requests)
+	}
+}
@@ -0,0 +1,34 @@
+package pypi
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	anchorRegex = regexp.MustCompile(`(?is)<a(\s[^>]*)?>(.*?)</a\s*>`)
+	attrRegex   = regexp.MustCompile(`(?s)([^\s"'<>/=]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+	tagRegex    = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+type anchor struct {
+	attrs map[string]string
+	text  string
+}
+
+func parseAnchors(page string) []anchor {
+	var anchors []anchor
+	for _, m := range anchorRegex.FindAllStringSubmatch(page, -1) {
+		a := anchor{
+			attrs: make(map[string]string),
+			text:  html.UnescapeString(tagRegex.ReplaceAllString(m[2], "")),
+		}
+		for _, attr := range attrRegex.FindAllStringSubmatch(m[1], -1) {
+			value := attr[2] + attr[3] + attr[4]
+			a.attrs[strings.ToLower(attr[1])] = html.UnescapeString(value)
+		}
+		anchors = append(anchors, a)
+	}
+	return anchors
+}
@@ -0,0 +1,517 @@
+package toml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrSyntax = errors.New("syntax error")
+
+var (
+	decimalRegex      = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)$`)
+	prefixedIntRegex  = regexp.MustCompile(`^(0x[0-9A-Fa-f](_?[0-9A-Fa-f])*|0o[0-7](_?[0-7])*|0b[01](_?[01])*)$`)
+	floatRegex        = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*([eE][+-]?[0-9](_?[0-9])*)?|[eE][+-]?[0-9](_?[0-9])*)$`)
+	specialFloatRegex = regexp.MustCompile(`^[+-]?(inf|nan)$`)
+)
+
+var dateTimeLayouts = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+	"15:04:05.999999999",
+}
+
+type parser struct {
+	data  string
+	pos   int
+	line  int
+	root  map[string]interface{}
+	table map[string]interface{}
+}
+
+func Decode(r io.Reader) (map[string]interface{}, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(data))
+}
+
+func Parse(s string) (map[string]interface{}, error) {
+	root := make(map[string]interface{})
+	p := &parser{data: s, line: 1, root: root, table: root}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w at line %d: %s", ErrSyntax, p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.data[p.pos]
+}
+
+func (p *parser) advance(n int) {
+	for i := 0; i < n && !p.eof(); i++ {
+		if p.data[p.pos] == '\n' {
+			p.line++
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) skipSpaces() {
+	for c := p.peek(); c == ' ' || c == '\t'; c = p.peek() {
+		p.advance(1)
+	}
+}
+
+func (p *parser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.advance(1)
+		}
+	}
+}
+
+func (p *parser) skipBlank() {
+	for {
+		p.skipSpaces()
+		p.skipComment()
+		switch p.peek() {
+		case '\n', '\r':
+			p.advance(1)
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) endOfLine() error {
+	p.skipSpaces()
+	p.skipComment()
+	if p.peek() == '\r' {
+		p.advance(1)
+	}
+	if !p.eof() && p.peek() != '\n' {
+		return p.errorf("unexpected %q after value", p.peek())
+	}
+	return nil
+}
+
+func (p *parser) parse() error {
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil
+		}
+		var err error
+		if p.peek() == '[' {
+			err = p.parseTableHeader()
+		} else {
+			err = p.parseKeyValue(p.table)
+		}
+		if err != nil {
+			return err
+		}
+		if err := p.endOfLine(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *parser) parseTableHeader() error {
+	array := strings.HasPrefix(p.data[p.pos:], "[[")
+	if array {
+		p.advance(2)
+	} else {
+		p.advance(1)
+	}
+	p.skipSpaces()
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.data[p.pos:], closing) {
+		return p.errorf("expected %q", closing)
+	}
+	p.advance(len(closing))
+	t, err := p.descend(p.root, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if array {
+		existing, ok := t[last]
+		if !ok {
+			existing = make([]interface{}, 0, 1)
+		}
+		tables, ok := existing.([]interface{})
+		if !ok {
+			return p.errorf("key %q is not an array of tables", last)
+		}
+		table := make(map[string]interface{})
+		t[last] = append(tables, table)
+		p.table = table
+		return nil
+	}
+	existing, ok := t[last]
+	if !ok {
+		existing = make(map[string]interface{})
+		t[last] = existing
+	}
+	table, ok := existing.(map[string]interface{})
+	if !ok {
+		return p.errorf("key %q is not a table", last)
+	}
+	p.table = table
+	return nil
+}
+
+func (p *parser) descend(t map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for _, k := range keys {
+		v, ok := t[k]
+		if !ok {
+			next := make(map[string]interface{})
+			t[k] = next
+			t = next
+			continue
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			t = v
+		case []interface{}:
+			if len(v) == 0 {
+				return nil, p.errorf("key %q is an empty array", k)
+			}
+			last, ok := v[len(v)-1].(map[string]interface{})
+			if !ok {
+				return nil, p.errorf("key %q is not an array of tables", k)
+			}
+			t = last
+		default:
+			return nil, p.errorf("key %q is not a table", k)
+		}
+	}
+	return t, nil
+}
+
+func (p *parser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpaces()
+		var key string
+		switch c := p.peek(); {
+		case c == '"':
+			s, err := p.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		case c == '\'':
+			s, err := p.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		default:
+			start := p.pos
+			for c := p.peek(); isBareKeyChar(c); c = p.peek() {
+				p.advance(1)
+			}
+			if start == p.pos {
+				return nil, p.errorf("expected key")
+			}
+			key = p.data[start:p.pos]
+		}
+		keys = append(keys, key)
+		p.skipSpaces()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.advance(1)
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *parser) parseKeyValue(t map[string]interface{}) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.peek() != '=' {
+		return p.errorf("expected '=' after key")
+	}
+	p.advance(1)
+	p.skipSpaces()
+	v, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	t, err = p.descend(t, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if _, ok := t[last]; ok {
+		return p.errorf("duplicate key %q", last)
+	}
+	t[last] = v
+	return nil
+}
+
+func (p *parser) parseValue() (interface{}, error) {
+	rest := p.data[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, `"""`):
+		return p.parseMultilineString(`"""`, true)
+	case strings.HasPrefix(rest, `'''`):
+		return p.parseMultilineString(`'''`, false)
+	case strings.HasPrefix(rest, `"`):
+		return p.parseBasicString()
+	case strings.HasPrefix(rest, `'`):
+		return p.parseLiteralString()
+	case strings.HasPrefix(rest, "["):
+		return p.parseArray()
+	case strings.HasPrefix(rest, "{"):
+		return p.parseInlineTable()
+	case strings.HasPrefix(rest, "true"):
+		p.advance(4)
+		return true, nil
+	case strings.HasPrefix(rest, "false"):
+		p.advance(5)
+		return false, nil
+	}
+	start := p.pos
+	for c := p.peek(); c != 0 && !strings.ContainsRune(",]}#\r\n", rune(c)); c = p.peek() {
+		p.advance(1)
+	}
+	raw := strings.TrimSpace(p.data[start:p.pos])
+	if raw == "" {
+		return nil, p.errorf("expected value")
+	}
+	if v, ok := parseScalar(raw); ok {
+		return v, nil
+	}
+	return nil, p.errorf("invalid value %q", raw)
+}
+
+func parseScalar(raw string) (interface{}, bool) {
+	number := strings.Replace(raw, "_", "", -1)
+	switch {
+	case decimalRegex.MatchString(raw):
+		i, err := strconv.ParseInt(number, 10, 64)
+		return i, err == nil
+	case prefixedIntRegex.MatchString(raw):
+		i, err := strconv.ParseInt(number, 0, 64)
+		return i, err == nil
+	case floatRegex.MatchString(raw):
+		f, err := strconv.ParseFloat(number, 64)
+		return f, err == nil
+	case specialFloatRegex.MatchString(raw):
+		if strings.HasSuffix(raw, "nan") {
+			return math.NaN(), true
+		}
+		f, err := strconv.ParseFloat(raw, 64)
+		return f, err == nil
+	}
+	s := strings.ToUpper(raw)
+	if len(s) > len("2006-01-02") && s[len("2006-01-02")] == ' ' {
+		s = s[:len("2006-01-02")] + "T" + s[len("2006-01-02")+1:]
+	}
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func (p *parser) parseBasicString() (string, error) {
+	p.advance(1)
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		if c == '"' {
+			p.advance(1)
+			return b.String(), nil
+		}
+		if c == '\\' {
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte(c)
+		p.advance(1)
+	}
+}
+
+func (p *parser) parseEscape(b *strings.Builder) error {
+	p.advance(1)
+	c := p.peek()
+	p.advance(1)
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case 'e':
+		b.WriteByte(0x1b)
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.data) {
+			return p.errorf("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(p.data[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return p.errorf("invalid unicode escape")
+		}
+		b.WriteRune(rune(code))
+		p.advance(n)
+	default:
+		return p.errorf("invalid escape sequence \\%c", c)
+	}
+	return nil
+}
+
+func (p *parser) parseLiteralString() (string, error) {
+	p.advance(1)
+	start := p.pos
+	for p.peek() != '\'' {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		p.advance(1)
+	}
+	s := p.data[start:p.pos]
+	p.advance(1)
+	return s, nil
+}
+
+func (p *parser) parseMultilineString(delim string, escapes bool) (string, error) {
+	p.advance(len(delim))
+	if strings.HasPrefix(p.data[p.pos:], "\r\n") {
+		p.advance(2)
+	} else if p.peek() == '\n' {
+		p.advance(1)
+	}
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		if strings.HasPrefix(p.data[p.pos:], delim) {
+			p.advance(len(delim))
+			for i := 0; i < 2 && p.peek() == delim[0]; i++ {
+				b.WriteByte(delim[0])
+				p.advance(1)
+			}
+			return b.String(), nil
+		}
+		c := p.peek()
+		if escapes && c == '\\' {
+			rest := strings.TrimLeft(p.data[p.pos+1:], " \t\r")
+			if strings.HasPrefix(rest, "\n") {
+				p.advance(len(p.data) - len(rest) - p.pos)
+				for c := p.peek(); c == ' ' || c == '\t' || c == '\r' || c == '\n'; c = p.peek() {
+					p.advance(1)
+				}
+				continue
+			}
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte(c)
+		p.advance(1)
+	}
+}
+
+func (p *parser) parseArray() ([]interface{}, error) {
+	p.advance(1)
+	values := make([]interface{}, 0)
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.advance(1)
+			return values, nil
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.advance(1)
+		case ']':
+		default:
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+func (p *parser) parseInlineTable() (map[string]interface{}, error) {
+	p.advance(1)
+	t := make(map[string]interface{})
+	for {
+		p.skipBlank()
+		if p.peek() == '}' {
+			p.advance(1)
+			return t, nil
+		}
+		if err := p.parseKeyValue(t); err != nil {
+			return nil, err
+		}
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.advance(1)
+		case '}':
+		default:
+			return nil, p.errorf("expected ',' or '}' in inline table")
+		}
+	}
+}
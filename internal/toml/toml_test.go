@@ -0,0 +1,356 @@
+package toml
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type table = map[string]interface{}
+type array = []interface{}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want table
+	}{
+		{
+			name: "poetry.lock",
+			raw: `# This file is automatically @generated by Poetry 1.8.3 and should not be changed by hand.
+
+[[package]]
+name = "certifi"
+version = "2024.7.4"
+description = "Python package for providing Mozilla's CA Bundle."
+optional = false
+python-versions = ">=3.6"
+files = [
+    {file = "certifi-2024.7.4-py3-none-any.whl", hash = "sha256:aaaa"},
+    {file = "certifi-2024.7.4.tar.gz", hash = "sha256:bbbb"},
+]
+
+[[package]]
+name = "requests"
+version = "2.32.3"
+description = "Python HTTP for Humans."
+optional = false
+python-versions = ">=3.8"
+files = [
+    {file = "requests-2.32.3-py3-none-any.whl", hash = "sha256:cccc"},
+]
+
+[package.dependencies]
+certifi = ">=2017.4.17"
+urllib3 = {version = ">=1.21.1,<3", markers = "python_version >= \"3.8\""}
+
+[package.extras]
+socks = ["PySocks (>=1.5.6,!=1.5.7)"]
+
+[package.source]
+type = "legacy"
+url = "https://example.com/simple"
+reference = "private"
+
+[metadata]
+lock-version = "2.0"
+python-versions = "^3.8"
+content-hash = "0123abcd"
+`,
+			want: table{
+				"package": array{
+					table{
+						"name":            "certifi",
+						"version":         "2024.7.4",
+						"description":     "Python package for providing Mozilla's CA Bundle.",
+						"optional":        false,
+						"python-versions": ">=3.6",
+						"files": array{
+							table{"file": "certifi-2024.7.4-py3-none-any.whl", "hash": "sha256:aaaa"},
+							table{"file": "certifi-2024.7.4.tar.gz", "hash": "sha256:bbbb"},
+						},
+					},
+					table{
+						"name":            "requests",
+						"version":         "2.32.3",
+						"description":     "Python HTTP for Humans.",
+						"optional":        false,
+						"python-versions": ">=3.8",
+						"files": array{
+							table{"file": "requests-2.32.3-py3-none-any.whl", "hash": "sha256:cccc"},
+						},
+						"dependencies": table{
+							"certifi": ">=2017.4.17",
+							"urllib3": table{"version": ">=1.21.1,<3", "markers": `python_version >= "3.8"`},
+						},
+						"extras": table{
+							"socks": array{"PySocks (>=1.5.6,!=1.5.7)"},
+						},
+						"source": table{
+							"type":      "legacy",
+							"url":       "https://example.com/simple",
+							"reference": "private",
+						},
+					},
+				},
+				"metadata": table{
+					"lock-version":    "2.0",
+					"python-versions": "^3.8",
+					"content-hash":    "0123abcd",
+				},
+			},
+		},
+		{
+			name: "legacy poetry.lock metadata files",
+			raw: `[metadata.files]
+six = [
+    {file = "six-1.16.0-py2.py3-none-any.whl", hash = "sha256:dddd"},
+]
+"zope.interface" = []
+`,
+			want: table{
+				"metadata": table{
+					"files": table{
+						"six": array{
+							table{"file": "six-1.16.0-py2.py3-none-any.whl", "hash": "sha256:dddd"},
+						},
+						"zope.interface": array{},
+					},
+				},
+			},
+		},
+		{
+			name: "uv.lock",
+			raw: `version = 1
+requires-python = ">=3.8"
+resolution-markers = [
+    "python_full_version >= '3.9'",
+    "python_full_version < '3.9'",
+]
+
+[[package]]
+name = "idna"
+version = "3.7"
+source = { registry = "https://pypi.org/simple" }
+sdist = { url = "https://files.pythonhosted.org/packages/21/ed/idna-3.7.tar.gz", hash = "sha256:eeee", size = 189575, upload-time = "2024-04-11T03:34:43.276Z" }
+wheels = [
+    { url = "https://files.pythonhosted.org/packages/e5/3e/idna-3.7-py3-none-any.whl", hash = "sha256:ffff", size = 66836 },
+]
+
+[[package]]
+name = "project"
+version = "0.1.0"
+source = { editable = "." }
+dependencies = [
+    { name = "idna" },
+]
+
+[package.metadata]
+requires-dist = [{ name = "idna", specifier = ">=3" }]
+`,
+			want: table{
+				"version":         int64(1),
+				"requires-python": ">=3.8",
+				"resolution-markers": array{
+					"python_full_version >= '3.9'",
+					"python_full_version < '3.9'",
+				},
+				"package": array{
+					table{
+						"name":    "idna",
+						"version": "3.7",
+						"source":  table{"registry": "https://pypi.org/simple"},
+						"sdist": table{
+							"url":         "https://files.pythonhosted.org/packages/21/ed/idna-3.7.tar.gz",
+							"hash":        "sha256:eeee",
+							"size":        int64(189575),
+							"upload-time": "2024-04-11T03:34:43.276Z",
+						},
+						"wheels": array{
+							table{
+								"url":  "https://files.pythonhosted.org/packages/e5/3e/idna-3.7-py3-none-any.whl",
+								"hash": "sha256:ffff",
+								"size": int64(66836),
+							},
+						},
+					},
+					table{
+						"name":         "project",
+						"version":      "0.1.0",
+						"source":       table{"editable": "."},
+						"dependencies": array{table{"name": "idna"}},
+						"metadata": table{
+							"requires-dist": array{table{"name": "idna", "specifier": ">=3"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "strings",
+			raw: `basic = "tab\there \"quoted\" \u00e9\U0001F600"
+literal = 'C:\Users\nodejs'
+multi = """
+Roses are red
+Violets are blue"""
+folded = """\
+    The quick brown \
+    fox."""
+raw = '''
+first line
+  second line'''
+quotes = """a ""quoted"" word"""""
+"quoted key" = 1
+dotted.key = 2
+`,
+			want: table{
+				"basic":      "tab\there \"quoted\" \u00e9\U0001F600",
+				"literal":    `C:\Users\nodejs`,
+				"multi":      "Roses are red\nViolets are blue",
+				"folded":     "The quick brown fox.",
+				"raw":        "first line\n  second line",
+				"quotes":     `a ""quoted"" word""`,
+				"quoted key": int64(1),
+				"dotted":     table{"key": int64(2)},
+			},
+		},
+		{
+			name: "numbers and booleans",
+			raw: `int = +99
+neg = -17
+zero = 0
+big = 1_000_000
+hex = 0xDEAD_beef
+oct = 0o755
+bin = 0b1101
+float = 3.1415
+exp = -2E-2
+both = 6.626e-34
+under = 224_617.445_991
+t = true
+f = false
+mixed = [1, 2.5, "x", [true], {a = 1}]
+`,
+			want: table{
+				"int":   int64(99),
+				"neg":   int64(-17),
+				"zero":  int64(0),
+				"big":   int64(1000000),
+				"hex":   int64(0xdeadbeef),
+				"oct":   int64(0755),
+				"bin":   int64(13),
+				"float": 3.1415,
+				"exp":   -2e-2,
+				"both":  6.626e-34,
+				"under": 224617.445991,
+				"t":     true,
+				"f":     false,
+				"mixed": array{int64(1), 2.5, "x", array{true}, table{"a": int64(1)}},
+			},
+		},
+		{
+			name: "dates and times",
+			raw: `odt1 = 1979-05-27T07:32:00Z
+odt2 = 1979-05-27T00:32:00.999999-07:00
+odt3 = 1979-05-27 07:32:00Z
+ldt = 1979-05-27t07:32:00
+ld = 1979-05-27
+lt = 00:32:00.5
+`,
+			want: table{
+				"odt1": time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC),
+				"odt2": time.Date(1979, 5, 27, 0, 32, 0, 999999000, time.FixedZone("", -7*3600)),
+				"odt3": time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC),
+				"ldt":  time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC),
+				"ld":   time.Date(1979, 5, 27, 0, 0, 0, 0, time.UTC),
+				"lt":   time.Date(0, 1, 1, 0, 32, 0, 500000000, time.UTC),
+			},
+		},
+		{
+			name: "comments and blank lines",
+			raw:  "# comment\r\n\r\n[a] # table\r\nb = 1 # trailing\r\n\n[a.c]\nd = 2\n",
+			want: table{"a": table{"b": int64(1), "c": table{"d": int64(2)}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Parse(test.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range test.want {
+				if tm, ok := v.(time.Time); ok {
+					if gotTm, ok := got[k].(time.Time); !ok || !gotTm.Equal(tm) {
+						t.Errorf("%s = %#v, want %v", k, got[k], tm)
+					}
+					got[k] = v
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %#v\nwant %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseSpecialFloats(t *testing.T) {
+	got, err := Parse("a = inf\nb = -inf\nc = nan\nd = +nan\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsInf(got["a"].(float64), 1) || !math.IsInf(got["b"].(float64), -1) {
+		t.Errorf("unexpected infinities %v %v", got["a"], got["b"])
+	}
+	if !math.IsNaN(got["c"].(float64)) || !math.IsNaN(got["d"].(float64)) {
+		t.Errorf("unexpected NaNs %v %v", got["c"], got["d"])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"a",
+		"a =",
+		"= 1",
+		"a = 1\na = 2",
+		"a = 1 2",
+		"a = bare",
+		"a = 1979-05-27T07:32",
+		"a = 1979-13-27",
+		"a = 25:00:00",
+		"a = 2024.7.4",
+		"a = 017",
+		"a = 1__000",
+		"a = 1_",
+		"a = .5",
+		"a = 1.",
+		"a = 0x",
+		"a = 0x1p-2",
+		"a = Inf",
+		"a = infinity",
+		"a = 99999999999999999999",
+		`a = "unterminated`,
+		"a = \"new\nline\"",
+		`a = 'unterminated`,
+		`a = """unterminated`,
+		`a = "\x"`,
+		`a = "\u00"`,
+		"a = [1, 2",
+		"a = [1 2]",
+		"a = {b = 1",
+		"a = {b = 1 c = 2}",
+		"[a",
+		"[[a]",
+		"a = 1\n[a]",
+		"[a]\n[[a]]",
+		"a = 1\n[a.b]",
+		"a = true false",
+		"[a] b = 1",
+	}
+	for _, raw := range tests {
+		if _, err := Parse(raw); !errors.Is(err, ErrSyntax) {
+			t.Errorf("Parse(%q) error = %v, want ErrSyntax", raw, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+type CoreMetadata struct {
+	MetadataVersion        string
+	Name                   string
+	Version                string
+	Summary                string
+	Description            string
+	DescriptionContentType string
+	Keywords               string
+	HomePage               string
+	DownloadURL            string
+	Author                 string
+	AuthorEmail            string
+	Maintainer             string
+	MaintainerEmail        string
+	License                string
+	LicenseExpression      string
+	RequiresPython         string
+	Platforms              []string
+	SupportedPlatforms     []string
+	Classifiers            []string
+	RequiresDist           []string
+	RequiresExternal       []string
+	ProvidesExtra          []string
+	ProvidesDist           []string
+	ObsoletesDist          []string
+	ProjectURLs            []string
+	LicenseFiles           []string
+	Dynamic                []string
+}
+
+type singleField func(*CoreMetadata) *string
+type multiField func(*CoreMetadata) *[]string
+
+var singleFields = map[string]singleField{
+	"metadata-version":         func(m *CoreMetadata) *string { return &m.MetadataVersion },
+	"name":                     func(m *CoreMetadata) *string { return &m.Name },
+	"version":                  func(m *CoreMetadata) *string { return &m.Version },
+	"summary":                  func(m *CoreMetadata) *string { return &m.Summary },
+	"description":              func(m *CoreMetadata) *string { return &m.Description },
+	"description-content-type": func(m *CoreMetadata) *string { return &m.DescriptionContentType },
+	"keywords":                 func(m *CoreMetadata) *string { return &m.Keywords },
+	"home-page":                func(m *CoreMetadata) *string { return &m.HomePage },
+	"download-url":             func(m *CoreMetadata) *string { return &m.DownloadURL },
+	"author":                   func(m *CoreMetadata) *string { return &m.Author },
+	"author-email":             func(m *CoreMetadata) *string { return &m.AuthorEmail },
+	"maintainer":               func(m *CoreMetadata) *string { return &m.Maintainer },
+	"maintainer-email":         func(m *CoreMetadata) *string { return &m.MaintainerEmail },
+	"license":                  func(m *CoreMetadata) *string { return &m.License },
+	"license-expression":       func(m *CoreMetadata) *string { return &m.LicenseExpression },
+	"requires-python":          func(m *CoreMetadata) *string { return &m.RequiresPython },
+}
+
+var multiFields = map[string]multiField{
+	"platform":           func(m *CoreMetadata) *[]string { return &m.Platforms },
+	"supported-platform": func(m *CoreMetadata) *[]string { return &m.SupportedPlatforms },
+	"classifier":         func(m *CoreMetadata) *[]string { return &m.Classifiers },
+	"requires-dist":      func(m *CoreMetadata) *[]string { return &m.RequiresDist },
+	"requires-external":  func(m *CoreMetadata) *[]string { return &m.RequiresExternal },
+	"provides-extra":     func(m *CoreMetadata) *[]string { return &m.ProvidesExtra },
+	"provides-dist":      func(m *CoreMetadata) *[]string { return &m.ProvidesDist },
+	"obsoletes-dist":     func(m *CoreMetadata) *[]string { return &m.ObsoletesDist },
+	"project-url":        func(m *CoreMetadata) *[]string { return &m.ProjectURLs },
+	"license-file":       func(m *CoreMetadata) *[]string { return &m.LicenseFiles },
+	"dynamic":            func(m *CoreMetadata) *[]string { return &m.Dynamic },
+}
+
+func ParseCoreMetadata(s string) (*CoreMetadata, error) {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	type header struct {
+		name  string
+		value string
+	}
+	var headers []*header
+	lines := strings.Split(s, "\n")
+	body := ""
+	for i, line := range lines {
+		if line == "" {
+			body = strings.Join(lines[i+1:], "\n")
+			break
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(headers) == 0 {
+				return nil, fmt.Errorf("%w: continuation line %d without header", errInvalidMetadata, i+1)
+			}
+			for _, prefix := range []string{"       |", "        "} {
+				if strings.HasPrefix(line, prefix) {
+					line = line[len(prefix):]
+					break
+				}
+			}
+			h := headers[len(headers)-1]
+			h.value += "\n" + line
+			continue
+		}
+		idx := strings.Index(line, ":")
+		if idx <= 0 {
+			return nil, fmt.Errorf("%w: malformed header line %d", errInvalidMetadata, i+1)
+		}
+		headers = append(headers, &header{
+			name:  strings.ToLower(strings.TrimSpace(line[:idx])),
+			value: strings.TrimSpace(line[idx+1:]),
+		})
+	}
+	meta := &CoreMetadata{}
+	for _, h := range headers {
+		if field, ok := singleFields[h.name]; ok {
+			*field(meta) = h.value
+		} else if field, ok := multiFields[h.name]; ok {
+			*field(meta) = append(*field(meta), h.value)
+		}
+	}
+	if meta.Description == "" {
+		meta.Description = strings.TrimRight(body, "\n")
+	}
+	return meta, nil
+}
+
+func (m *CoreMetadata) ProjectURL(label string) string {
+	label = normalizeLabel(label)
+	for _, u := range m.ProjectURLs {
+		idx := strings.Index(u, ",")
+		if idx == -1 {
+			continue
+		}
+		if normalizeLabel(u[:idx]) == label {
+			return strings.TrimSpace(u[idx+1:])
+		}
+	}
+	return ""
+}
+
+func (m *CoreMetadata) Homepage() string {
+	if m.HomePage != "" {
+		return m.HomePage
+	}
+	return m.ProjectURL("homepage")
+}
+
+func normalizeLabel(label string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '-', '_', '.':
+			return -1
+		}
+		return r
+	}, strings.ToLower(label))
+}
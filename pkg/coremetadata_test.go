@@ -0,0 +1,245 @@
+package pkg
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseCoreMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want *CoreMetadata
+	}{
+		{
+			name: "metadata 1.0 with folded description",
+			raw: "Metadata-Version: 1.0\n" +
+				"Name: simplejson\n" +
+				"Version: 2.0.9\n" +
+				"Summary: Simple, fast, extensible JSON encoder/decoder for Python\n" +
+				"Home-page: http://undefined.org/python/#simplejson\n" +
+				"Author: Bob Ippolito\n" +
+				"Author-email: bob@redivi.com\n" +
+				"License: MIT License\n" +
+				"Description: simplejson is a simple, fast, complete, correct and extensible\n" +
+				"        JSON <http://json.org> encoder and decoder for Python 2.4+.\n" +
+				"        \n" +
+				"        It is pure Python code with no dependencies.\n" +
+				"Platform: any\n",
+			want: &CoreMetadata{
+				MetadataVersion: "1.0",
+				Name:            "simplejson",
+				Version:         "2.0.9",
+				Summary:         "Simple, fast, extensible JSON encoder/decoder for Python",
+				HomePage:        "http://undefined.org/python/#simplejson",
+				Author:          "Bob Ippolito",
+				AuthorEmail:     "bob@redivi.com",
+				License:         "MIT License",
+				Description: "simplejson is a simple, fast, complete, correct and extensible\n" +
+					"JSON <http://json.org> encoder and decoder for Python 2.4+.\n" +
+					"\n" +
+					"It is pure Python code with no dependencies.",
+				Platforms: []string{"any"},
+			},
+		},
+		{
+			name: "metadata 1.1 with classifiers",
+			raw: `Metadata-Version: 1.1
+Name: six
+Version: 1.10.0
+Summary: Python 2 and 3 compatibility utilities
+Home-page: http://pypi.python.org/pypi/six/
+License: MIT
+Description: UNKNOWN
+Platform: UNKNOWN
+Classifier: Programming Language :: Python :: 2
+Classifier: Programming Language :: Python :: 3
+Classifier: License :: OSI Approved :: MIT License
+`,
+			want: &CoreMetadata{
+				MetadataVersion: "1.1",
+				Name:            "six",
+				Version:         "1.10.0",
+				Summary:         "Python 2 and 3 compatibility utilities",
+				HomePage:        "http://pypi.python.org/pypi/six/",
+				License:         "MIT",
+				Description:     "UNKNOWN",
+				Platforms:       []string{"UNKNOWN"},
+				Classifiers: []string{
+					"Programming Language :: Python :: 2",
+					"Programming Language :: Python :: 3",
+					"License :: OSI Approved :: MIT License",
+				},
+			},
+		},
+		{
+			name: "metadata 1.2 with pipe folded description",
+			raw: `Metadata-Version: 1.2
+Name: pytz
+Version: 2019.3
+Requires-Python: >=2.7, !=3.0.*
+Description: pytz - World Timezone Definitions for Python
+       |============================================
+       |
+       |:Author: Stuart Bishop
+       |
+       |    >>> from pytz import timezone
+Requires-Dist: tzdata; python_version >= "3.9"
+Project-URL: Source, https://github.com/stub42/pytz
+`,
+			want: &CoreMetadata{
+				MetadataVersion: "1.2",
+				Name:            "pytz",
+				Version:         "2019.3",
+				RequiresPython:  ">=2.7, !=3.0.*",
+				Description: "pytz - World Timezone Definitions for Python\n" +
+					"============================================\n" +
+					"\n" +
+					":Author: Stuart Bishop\n" +
+					"\n" +
+					"    >>> from pytz import timezone",
+				RequiresDist: []string{`tzdata; python_version >= "3.9"`},
+				ProjectURLs:  []string{"Source, https://github.com/stub42/pytz"},
+			},
+		},
+		{
+			name: "metadata 2.1 with description in the body",
+			raw: `Metadata-Version: 2.1
+Name: requests
+Version: 2.31.0
+Summary: Python HTTP for Humans.
+Requires-Python: >=3.7
+Description-Content-Type: text/markdown
+Requires-Dist: charset-normalizer (<4,>=2)
+Requires-Dist: idna (<4,>=2.5)
+Requires-Dist: PySocks (!=1.5.7,>=1.5.6) ; extra == 'socks'
+Provides-Extra: socks
+Project-URL: Documentation, https://requests.readthedocs.io
+
+# Requests
+
+Key: value lines in the body are not headers.
+
+`,
+			want: &CoreMetadata{
+				MetadataVersion:        "2.1",
+				Name:                   "requests",
+				Version:                "2.31.0",
+				Summary:                "Python HTTP for Humans.",
+				RequiresPython:         ">=3.7",
+				DescriptionContentType: "text/markdown",
+				RequiresDist: []string{
+					"charset-normalizer (<4,>=2)",
+					"idna (<4,>=2.5)",
+					"PySocks (!=1.5.7,>=1.5.6) ; extra == 'socks'",
+				},
+				ProvidesExtra: []string{"socks"},
+				ProjectURLs:   []string{"Documentation, https://requests.readthedocs.io"},
+				Description:   "# Requests\n\nKey: value lines in the body are not headers.",
+			},
+		},
+		{
+			name: "metadata 2.2 with dynamic fields",
+			raw: "Metadata-Version: 2.2\r\n" +
+				"Name: example\r\n" +
+				"Version: 1.0\r\n" +
+				"Dynamic: Requires-Dist\r\n" +
+				"Dynamic: Requires-Python\r\n" +
+				"\r\n" +
+				"Body\r\n",
+			want: &CoreMetadata{
+				MetadataVersion: "2.2",
+				Name:            "example",
+				Version:         "1.0",
+				Dynamic:         []string{"Requires-Dist", "Requires-Python"},
+				Description:     "Body",
+			},
+		},
+		{
+			name: "metadata 2.3 with mixed case headers",
+			raw: `metadata-version: 2.3
+NAME: Example_Pkg
+version: 0.1.0
+License-File: LICENSE
+Supported-Platform: RedHat 7.2
+Requires-External: libpng (>=1.5)
+Provides-Dist: example-pkg
+Obsoletes-Dist: old-example
+`,
+			want: &CoreMetadata{
+				MetadataVersion:    "2.3",
+				Name:               "Example_Pkg",
+				Version:            "0.1.0",
+				LicenseFiles:       []string{"LICENSE"},
+				SupportedPlatforms: []string{"RedHat 7.2"},
+				RequiresExternal:   []string{"libpng (>=1.5)"},
+				ProvidesDist:       []string{"example-pkg"},
+				ObsoletesDist:      []string{"old-example"},
+			},
+		},
+		{
+			name: "folded fields keep their indentation and pipes",
+			raw: "Metadata-Version: 1.0\n" +
+				"Name: indented\n" +
+				"Version: 1.0\n" +
+				"License: first line\n" +
+				"          indented | line\n" +
+				"\tline folded with a tab\n",
+			want: &CoreMetadata{
+				MetadataVersion: "1.0",
+				Name:            "indented",
+				Version:         "1.0",
+				License:         "first line\n  indented | line\n\tline folded with a tab",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCoreMetadata(tt.raw)
+			if err != nil {
+				t.Fatalf("ParseCoreMetadata() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCoreMetadata() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCoreMetadataErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"stray continuation", "  stray continuation\nMetadata-Version: 1.0\nName: broken\n"},
+		{"line without a colon", "Metadata-Version: 1.0\nName: broken\na line without a colon\n"},
+		{"empty header name", "Metadata-Version: 1.0\n: broken\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCoreMetadata(tt.raw); !errors.Is(err, errInvalidMetadata) {
+				t.Errorf("ParseCoreMetadata() error = %v, want errInvalidMetadata", err)
+			}
+		})
+	}
+}
+
+func TestCoreMetadataHomepage(t *testing.T) {
+	tests := []struct {
+		name string
+		meta *CoreMetadata
+		want string
+	}{
+		{"home-page", &CoreMetadata{HomePage: "https://a.example"}, "https://a.example"},
+		{"project-url", &CoreMetadata{ProjectURLs: []string{"Source, https://b.example", "Home_Page, https://c.example"}}, "https://c.example"},
+		{"none", &CoreMetadata{ProjectURLs: []string{"Source, https://b.example"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.meta.Homepage(); got != tt.want {
+				t.Errorf("Homepage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
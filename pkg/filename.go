@@ -0,0 +1,48 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+var sdistExts = []string{".tar.bz2", ".tar.gz", ".zip"}
+
+func IsSupported(filename string) bool {
+	for ext := range getters {
+		if strings.HasSuffix(filename, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func VersionFromFilename(filename string, name string) (string, error) {
+	if KindOf(filename) == KindWheel {
+		info, err := ParseWheelFilename(filename)
+		if err != nil {
+			return "", err
+		}
+		return info.Version, nil
+	}
+	stem := ""
+	for _, ext := range sdistExts {
+		if strings.HasSuffix(filename, ext) {
+			stem = strings.TrimSuffix(filename, ext)
+			break
+		}
+	}
+	if stem == "" {
+		return "", fmt.Errorf("%w %q", errUnknownExtension, filename)
+	}
+	normName := Normalize(name)
+	for i := 0; i < len(stem); i++ {
+		if stem[i] == '-' && Normalize(stem[:i]) == normName {
+			return stem[i+1:], nil
+		}
+	}
+	idx := strings.LastIndex(stem, "-")
+	if idx == -1 {
+		return "", fmt.Errorf("%w %q", errInvalidArchiveName, filename)
+	}
+	return stem[idx+1:], nil
+}
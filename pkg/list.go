@@ -1,29 +1,59 @@
 package pkg
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+
+	"github.com/montag451/go-pypi-mirror/internal/pool"
 )
 
-func List(dir string, fixNames bool) ([]*Pkg, error) {
-	pkgs := make([]*Pkg, 0)
+func DefaultWorkers() int {
+	return runtime.NumCPU()
+}
+
+func newAll(ctx context.Context, paths []string, workers int) ([]*Pkg, error) {
+	pkgs := make([]*Pkg, len(paths))
+	err := pool.Run(ctx, len(paths), workers, func(i int) error {
+		p, err := New(paths[i])
+		if err != nil {
+			return err
+		}
+		pkgs[i] = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pkgs, nil
+}
+
+func List(ctx context.Context, dir string, fixNames bool, workers int) ([]*Pkg, error) {
+	paths := make([]string, 0)
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() && !strings.HasSuffix(path, metadataExt) {
-			p, err := New(path)
-			if err != nil {
-				return err
-			}
-			pkgs = append(pkgs, p)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+		if !strings.HasSuffix(path, MetadataExt) {
+			paths = append(paths, path)
 		}
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
+	pkgs, err := newAll(ctx, paths, workers)
+	if err != nil {
+		return nil, err
+	}
 	if fixNames {
 		for _, group := range GroupByNormName(pkgs) {
 			FixNames(group.Pkgs)
@@ -32,8 +62,8 @@ func List(dir string, fixNames bool) ([]*Pkg, error) {
 	return pkgs, nil
 }
 
-func ListNames(dir string) ([]string, error) {
-	pkgs, err := List(dir, true)
+func ListNames(ctx context.Context, dir string, workers int) ([]string, error) {
+	pkgs, err := List(ctx, dir, true, workers)
 	if err != nil {
 		return nil, err
 	}
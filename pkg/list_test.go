@@ -0,0 +1,45 @@
+package pkg
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCreateMetadataFilesUpgradesSidecars(t *testing.T) {
+	dir, err := ioutil.TempDir("", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeTestWheel(t, dir, "foo", "1.0")
+	hash, err := HashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := `{"name": "foo", "norm_name": "foo", "version": "1.0", "homepage": "", "trusted": true, "sha256": "` + hash + `"}`
+	if err := ioutil.WriteFile(path+MetadataExt, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := List(context.Background(), dir, true, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || pkgs[0].Metadata.CoreMetadataHash == "" || !pkgs[0].Metadata.Stale() {
+		t.Fatalf("core metadata hash not backfilled")
+	}
+	if data, err := ioutil.ReadFile(path + MetadataExt); err != nil || string(data) != legacy {
+		t.Fatalf("List must not rewrite sidecars")
+	}
+	if err := CreateMetadataFiles(context.Background(), dir, false, 1); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := ReadMetadataFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.SchemaVersion != metadataSchemaVersion || meta.CoreMetadataHash != pkgs[0].Metadata.CoreMetadataHash {
+		t.Errorf("upgraded metadata not saved: %+v", meta)
+	}
+}
@@ -0,0 +1,185 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/montag451/go-pypi-mirror/internal/toml"
+)
+
+type LockedPackage struct {
+	Name    string
+	Version string
+	Hashes  []string
+	Files   []string
+	Marker  Marker
+}
+
+func (p *LockedPackage) NormName() string {
+	return Normalize(p.Name)
+}
+
+func (p *LockedPackage) Digests() map[string]map[string]bool {
+	digests := make(map[string]map[string]bool)
+	for _, h := range p.Hashes {
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		algo := strings.ToLower(kv[0])
+		if digests[algo] == nil {
+			digests[algo] = make(map[string]bool)
+		}
+		digests[algo][strings.ToLower(kv[1])] = true
+	}
+	return digests
+}
+
+func ParseLockFile(path string) ([]*LockedPackage, error) {
+	var pkgs []*LockedPackage
+	var err error
+	switch filepath.Base(path) {
+	case "poetry.lock":
+		pkgs, err = parsePoetryLock(path)
+	case "uv.lock":
+		pkgs, err = parseUvLock(path)
+	default:
+		pkgs, err = parseRequirementsLock(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %q: %w", path, err)
+	}
+	return pkgs, nil
+}
+
+func parseRequirementsLock(path string) ([]*LockedPackage, error) {
+	reqs, err := ParseRequirementsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make([]*LockedPackage, 0, len(reqs))
+	for _, req := range reqs {
+		spec := strings.TrimSpace(req.Specifier)
+		var version string
+		switch {
+		case strings.HasPrefix(spec, "==="):
+			version = strings.TrimSpace(spec[3:])
+		case strings.HasPrefix(spec, "=="):
+			version = strings.TrimSpace(spec[2:])
+		}
+		if version == "" || strings.ContainsAny(version, ",*") {
+			return nil, fmt.Errorf("requirement %q is not pinned", req.Name+req.Specifier)
+		}
+		pkgs = append(pkgs, &LockedPackage{
+			Name:    req.Name,
+			Version: version,
+			Hashes:  req.Hashes,
+			Marker:  req.Marker,
+		})
+	}
+	return pkgs, nil
+}
+
+func decodeTOMLFile(path string) (map[string]interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return toml.Decode(f)
+}
+
+func tomlString(t map[string]interface{}, key string) string {
+	s, _ := t[key].(string)
+	return s
+}
+
+func tomlTable(t map[string]interface{}, key string) map[string]interface{} {
+	table, _ := t[key].(map[string]interface{})
+	return table
+}
+
+func tomlTables(t map[string]interface{}, key string) []map[string]interface{} {
+	values, _ := t[key].([]interface{})
+	tables := make([]map[string]interface{}, 0, len(values))
+	for _, v := range values {
+		if table, ok := v.(map[string]interface{}); ok {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+func parsePoetryLock(path string) ([]*LockedPackage, error) {
+	doc, err := decodeTOMLFile(path)
+	if err != nil {
+		return nil, err
+	}
+	metadataFiles := tomlTable(tomlTable(doc, "metadata"), "files")
+	legacyFiles := make(map[string][]map[string]interface{}, len(metadataFiles))
+	for name := range metadataFiles {
+		legacyFiles[Normalize(name)] = tomlTables(metadataFiles, name)
+	}
+	var pkgs []*LockedPackage
+	for _, p := range tomlTables(doc, "package") {
+		switch tomlString(tomlTable(p, "source"), "type") {
+		case "", "legacy":
+		default:
+			continue
+		}
+		locked := &LockedPackage{Name: tomlString(p, "name"), Version: tomlString(p, "version")}
+		if locked.Name == "" || locked.Version == "" {
+			return nil, errors.New("package entry without name or version")
+		}
+		files := tomlTables(p, "files")
+		if len(files) == 0 {
+			files = legacyFiles[locked.NormName()]
+		}
+		for _, f := range files {
+			if filename := tomlString(f, "file"); filename != "" {
+				locked.Files = append(locked.Files, filename)
+			}
+			if hash := tomlString(f, "hash"); hash != "" {
+				locked.Hashes = append(locked.Hashes, hash)
+			}
+		}
+		pkgs = append(pkgs, locked)
+	}
+	return pkgs, nil
+}
+
+func parseUvLock(filePath string) ([]*LockedPackage, error) {
+	doc, err := decodeTOMLFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []*LockedPackage
+	for _, p := range tomlTables(doc, "package") {
+		if _, ok := tomlTable(p, "source")["registry"]; !ok {
+			continue
+		}
+		locked := &LockedPackage{Name: tomlString(p, "name"), Version: tomlString(p, "version")}
+		if locked.Name == "" || locked.Version == "" {
+			return nil, errors.New("package entry without name or version")
+		}
+		artifacts := tomlTables(p, "wheels")
+		if sdist := tomlTable(p, "sdist"); sdist != nil {
+			artifacts = append(artifacts, sdist)
+		}
+		for _, a := range artifacts {
+			if u, err := url.Parse(tomlString(a, "url")); err == nil && u.Path != "" {
+				locked.Files = append(locked.Files, path.Base(u.Path))
+			}
+			if hash := tomlString(a, "hash"); hash != "" {
+				locked.Hashes = append(locked.Hashes, hash)
+			}
+		}
+		pkgs = append(pkgs, locked)
+	}
+	return pkgs, nil
+}
@@ -5,6 +5,7 @@ import (
 	"archive/zip"
 	"compress/bzip2"
 	"compress/gzip"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"errors"
@@ -17,19 +18,17 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"github.com/montag451/go-pypi-mirror/internal/fsutil"
 )
 
 const (
-	metadataExt         = ".metadata.json"
-	archiveMetadataFile = "PKG-INFO"
+	MetadataExt           = ".metadata.json"
+	archiveMetadataFile   = "PKG-INFO"
+	metadataSchemaVersion = 2
 )
 
-var (
-	normRegex     = regexp.MustCompile("[-_.]+")
-	nameRegex     = regexp.MustCompile("(?m:^Name: (.*)$)")
-	versionRegex  = regexp.MustCompile("(?m:^Version: (.*)$)")
-	homepageRegex = regexp.MustCompile("(?m:^(?:Home-[pP]age:|Project-URL: [Hh]ome-?[pP]age,) (.*)$)")
-)
+var normRegex = regexp.MustCompile("[-_.]+")
 
 var (
 	errInvalidMetadata       = errors.New("invalid metadata")
@@ -42,6 +41,19 @@ var (
 type getMetadataFunc func(string) (*Metadata, error)
 type extractFunc func(string, string) (string, error)
 
+var coreMetadataExtractors = map[string]func(string) (string, error){
+	".tar.bz2": func(path string) (string, error) {
+		return extractSdistMetadata(path, ".tar.bz2", extractMemberFromTar)
+	},
+	".tar.gz": func(path string) (string, error) {
+		return extractSdistMetadata(path, ".tar.gz", extractMemberFromTar)
+	},
+	".whl": extractWheelMetadata,
+	".zip": func(path string) (string, error) {
+		return extractSdistMetadata(path, ".zip", extractMemberFromZip)
+	},
+}
+
 var getters = map[string]getMetadataFunc{
 	".tar.bz2": getMetadataFromTarBz2,
 	".tar.gz":  getMetadataFromTarGz,
@@ -50,44 +62,71 @@ var getters = map[string]getMetadataFunc{
 }
 
 type Metadata struct {
-	Name     string `json:"name"`
-	NormName string `json:"norm_name"`
-	Version  string `json:"version"`
-	Homepage string `json:"homepage"`
-	Trusted  bool   `json:"trusted"`
-	Hash     string `json:"sha256"`
+	SchemaVersion    int      `json:"schema_version,omitempty"`
+	Name             string   `json:"name"`
+	NormName         string   `json:"norm_name"`
+	Version          string   `json:"version"`
+	Homepage         string   `json:"homepage"`
+	RequiresPython   string   `json:"requires_python,omitempty"`
+	Trusted          bool     `json:"trusted"`
+	Hash             string   `json:"sha256"`
+	CoreMetadataHash string   `json:"core_metadata_sha256,omitempty"`
+	BuildTag         string   `json:"build_tag,omitempty"`
+	PythonTags       []string `json:"python_tags,omitempty"`
+	ABITags          []string `json:"abi_tags,omitempty"`
+	PlatformTags     []string `json:"platform_tags,omitempty"`
+	Source           *Source  `json:"source,omitempty"`
+	stale            bool
 }
 
-func (c *Metadata) MarshalJSON(w io.Writer) error {
+type Source struct {
+	IndexURL     string            `json:"index_url"`
+	URL          string            `json:"url"`
+	Digests      map[string]string `json:"digests,omitempty"`
+	UploadTime   string            `json:"upload_time,omitempty"`
+	DownloadTime string            `json:"download_time"`
+}
+
+func (m *Metadata) VerifySource() error {
+	if m.Source == nil {
+		return nil
+	}
+	expected, ok := m.Source.Digests["sha256"]
+	if !ok {
+		return nil
+	}
+	if !strings.EqualFold(expected, m.Hash) {
+		return fmt.Errorf("%w: upstream sha256 %s, got %s", ErrHashMismatch, expected, m.Hash)
+	}
+	return nil
+}
+
+func (c *Metadata) Encode(w io.Writer) error {
 	return json.NewEncoder(w).Encode(c)
 }
 
-func normalize(name string) string {
+func Normalize(name string) string {
 	return strings.ToLower(normRegex.ReplaceAllLiteralString(name, "-"))
 }
 
 func parseMetadata(s string) (*Metadata, error) {
-	m := nameRegex.FindStringSubmatch(s)
-	if len(m) == 0 {
+	core, err := ParseCoreMetadata(s)
+	if err != nil {
+		return nil, err
+	}
+	if core.Name == "" {
 		return nil, fmt.Errorf("%w: missing %q field", errInvalidMetadata, "Name")
 	}
-	name := strings.TrimSpace(m[1])
-	m = versionRegex.FindStringSubmatch(s)
-	if len(m) == 0 {
+	if core.Version == "" {
 		return nil, fmt.Errorf("%w: missing %q field", errInvalidMetadata, "Version")
 	}
-	version := strings.TrimSpace(m[1])
-	m = homepageRegex.FindStringSubmatch(s)
-	var homepage string
-	if len(m) != 0 {
-		homepage = strings.TrimSpace(m[1])
-	}
 	meta := &Metadata{
-		Name:     name,
-		NormName: normalize(name),
-		Version:  version,
-		Homepage: homepage,
-		Trusted:  true,
+		Name:           core.Name,
+		NormName:       Normalize(core.Name),
+		Version:        core.Version,
+		Homepage:       core.Homepage(),
+		RequiresPython: core.RequiresPython,
+		Trusted:        true,
 	}
 	return meta, nil
 }
@@ -115,7 +154,7 @@ func getMetadataFromArchive(filePath string, ext string, fn extractFunc, member
 		version := prefix[idx+1:]
 		meta := &Metadata{
 			Name:     name,
-			NormName: normalize(name),
+			NormName: Normalize(name),
 			Version:  version,
 			Trusted:  true,
 		}
@@ -185,6 +224,15 @@ func extractMemberFromTar(filePath string, member string) (string, error) {
 	return "", err
 }
 
+func extractSdistMetadata(filePath string, ext string, fn extractFunc) (string, error) {
+	filename := filepath.Base(filePath)
+	if !strings.HasSuffix(filename, ext) {
+		return "", errInvalidArchiveName
+	}
+	prefix := strings.TrimSuffix(filename, ext)
+	return fn(filePath, path.Join(prefix, archiveMetadataFile))
+}
+
 func getMetadataFromTarBz2(path string) (*Metadata, error) {
 	return getMetadataFromArchive(path, ".tar.bz2", extractMemberFromTar, "")
 }
@@ -193,16 +241,16 @@ func getMetadataFromTarGz(path string) (*Metadata, error) {
 	return getMetadataFromArchive(path, ".tar.gz", extractMemberFromTar, "")
 }
 
-func getMetadataFromWheel(filePath string) (*Metadata, error) {
+func extractWheelMetadata(filePath string) (string, error) {
 	whl, err := zip.OpenReader(filePath)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	defer whl.Close()
 	whlName := filepath.Base(filePath)
 	components := strings.SplitN(whlName, "-", 3)
 	if len(components) != 3 {
-		return nil, errInvalidArchiveName
+		return "", errInvalidArchiveName
 	}
 	prefix := strings.Join(components[:2], "-")
 	prefixes := []string{
@@ -217,19 +265,32 @@ func getMetadataFromWheel(filePath string) (*Metadata, error) {
 			break
 		}
 		if !errors.Is(err, errArchiveMemberNotFound) {
-			return nil, err
+			return "", err
 		}
 	}
 	if err != nil {
 		if errors.Is(err, errArchiveMemberNotFound) {
 			err = errors.New("metadata file not found")
 		}
+		return "", err
+	}
+	return rawMeta, nil
+}
+
+func getMetadataFromWheel(filePath string) (*Metadata, error) {
+	rawMeta, err := extractWheelMetadata(filePath)
+	if err != nil {
 		return nil, err
 	}
+	whlName := filepath.Base(filePath)
 	meta, err := parseMetadata(rawMeta)
 	if err != nil {
 		return nil, err
 	}
+	meta.CoreMetadataHash = fmt.Sprintf("%x", sha256.Sum256([]byte(rawMeta)))
+	if err := meta.setWheelTags(whlName); err != nil {
+		return nil, err
+	}
 	if !strings.HasPrefix(whlName, meta.Name) {
 		meta.Trusted = false
 		u, err := url.Parse(meta.Homepage)
@@ -254,13 +315,14 @@ func getMetadataFromZip(path string) (*Metadata, error) {
 }
 
 func getMetadataFromJSON(path string) (*Metadata, error) {
-	f, err := os.Open(path + metadataExt)
+	f, err := os.Open(path + MetadataExt)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return nil, nil
 		}
 		return nil, err
 	}
+	defer f.Close()
 	var meta Metadata
 	err = json.NewDecoder(f).Decode(&meta)
 	if err != nil {
@@ -275,42 +337,81 @@ func getMetadataFromJSON(path string) (*Metadata, error) {
 func getMetadata(path string) (*Metadata, error) {
 	meta, err := getMetadataFromJSON(path)
 	if err == nil && meta != nil {
+		if KindOf(path) == KindWheel && meta.PythonTags == nil {
+			if err := meta.setWheelTags(filepath.Base(path)); err != nil {
+				return nil, err
+			}
+		}
+		meta.upgrade(path)
 		return meta, nil
 	}
-	var getter getMetadataFunc
-	for ext, g := range getters {
+	return getMetadataFromFile(path)
+}
+
+func (m *Metadata) upgrade(path string) {
+	if m.SchemaVersion >= metadataSchemaVersion {
+		return
+	}
+	fresh, err := getMetadataFromArchiveFile(path)
+	if err != nil {
+		return
+	}
+	m.RequiresPython = fresh.RequiresPython
+	m.CoreMetadataHash = fresh.CoreMetadataHash
+	m.SchemaVersion = metadataSchemaVersion
+	m.stale = true
+}
+
+func (m *Metadata) Stale() bool {
+	return m.stale
+}
+
+func getMetadataFromArchiveFile(path string) (*Metadata, error) {
+	for ext, getter := range getters {
 		if strings.HasSuffix(path, ext) {
-			getter = g
-			break
+			return getter(path)
 		}
 	}
-	if getter == nil {
-		return nil, errUnknownExtension
+	return nil, errUnknownExtension
+}
+
+func getMetadataFromFile(path string) (*Metadata, error) {
+	meta, err := getMetadataFromArchiveFile(path)
+	if err != nil {
+		return nil, err
 	}
-	meta, err = getter(path)
+	meta.SchemaVersion = metadataSchemaVersion
+	meta.Hash, err = HashFile(path)
 	if err != nil {
 		return nil, err
 	}
+	return meta, nil
+}
+
+func HashFile(path string) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	defer f.Close()
 	h := sha256.New()
 	if _, err := io.Copy(h, f); err != nil {
-		return nil, err
+		return "", err
 	}
-	meta.Hash = fmt.Sprintf("%x", h.Sum(nil))
-	return meta, nil
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func ReadMetadataFile(path string) (*Metadata, error) {
+	return getMetadataFromJSON(path)
 }
 
-func CreateMetadataFiles(dir string, overwrite bool) error {
+func CreateMetadataFiles(ctx context.Context, dir string, overwrite bool, workers int) error {
 	if overwrite {
 		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
-			if !info.IsDir() && strings.HasSuffix(path, metadataExt) {
+			if !info.IsDir() && strings.HasSuffix(path, MetadataExt) {
 				err := os.Remove(path)
 				if err != nil {
 					return err
@@ -322,24 +423,28 @@ func CreateMetadataFiles(dir string, overwrite bool) error {
 			return err
 		}
 	}
-	pkgs, err := List(dir, true)
+	pkgs, err := List(ctx, dir, true, workers)
 	if err != nil {
 		return err
 	}
 	for _, pkg := range pkgs {
-		metadataFile := pkg.Path + metadataExt
-		if _, err := os.Stat(metadataFile); !errors.Is(err, os.ErrNotExist) {
-			continue
-		}
-		f, err := os.Create(metadataFile)
-		if err != nil {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
-		err = pkg.Metadata.MarshalJSON(f)
-		if err != nil {
+		metadataFile := pkg.MetadataPath()
+		if _, err := os.Stat(metadataFile); !errors.Is(err, os.ErrNotExist) && !pkg.Metadata.stale {
+			continue
+		}
+		if err := WriteMetadataFile(pkg); err != nil {
 			return err
 		}
-		f.Close()
 	}
 	return nil
 }
+
+func WriteMetadataFile(p *Pkg) error {
+	if err := p.Metadata.VerifySource(); err != nil {
+		return fmt.Errorf("refusing to write metadata file of %q: %w", p.Path, err)
+	}
+	return fsutil.WriteFile(p.MetadataPath(), 0644, p.Metadata.Encode)
+}
@@ -1,13 +1,22 @@
 package pkg
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrNoCoreMetadata = errors.New("no core metadata available")
+	ErrHashMismatch   = errors.New("hash mismatch")
 )
 
 type Pkg struct {
 	Path     string
 	Filename string
+	Kind     Kind
 	Metadata *Metadata
 }
 
@@ -16,5 +25,58 @@ func New(path string) (*Pkg, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error while processing %q: %w", path, err)
 	}
-	return &Pkg{path, filepath.Base(path), meta}, nil
+	filename := filepath.Base(path)
+	return &Pkg{path, filename, KindOf(filename), meta}, nil
+}
+
+func NewFromFile(path string) (*Pkg, error) {
+	meta, err := getMetadataFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while processing %q: %w", path, err)
+	}
+	filename := filepath.Base(path)
+	return &Pkg{path, filename, KindOf(filename), meta}, nil
+}
+
+func (p *Pkg) CoreMetadata() ([]byte, error) {
+	if p.Kind != KindWheel {
+		return nil, ErrNoCoreMetadata
+	}
+	rawMeta, err := extractWheelMetadata(p.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error while processing %q: %w", p.Path, err)
+	}
+	return []byte(rawMeta), nil
+}
+
+func (p *Pkg) ReadCoreMetadata() (*CoreMetadata, error) {
+	var extract func(string) (string, error)
+	for ext, e := range coreMetadataExtractors {
+		if strings.HasSuffix(p.Filename, ext) {
+			extract = e
+			break
+		}
+	}
+	if extract == nil {
+		return nil, ErrNoCoreMetadata
+	}
+	rawMeta, err := extract(p.Path)
+	if err != nil {
+		if errors.Is(err, errArchiveMemberNotFound) {
+			return nil, ErrNoCoreMetadata
+		}
+		return nil, fmt.Errorf("error while processing %q: %w", p.Path, err)
+	}
+	return ParseCoreMetadata(rawMeta)
+}
+
+func (p *Pkg) MetadataPath() string {
+	return p.Path + MetadataExt
+}
+
+func (p *Pkg) Remove() error {
+	if err := os.Remove(p.MetadataPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Remove(p.Path)
 }
@@ -0,0 +1,356 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var errInvalidRequirement = errors.New("invalid requirement")
+
+type Requirement struct {
+	Name      string
+	Extras    []string
+	Specifier string
+	URL       string
+	Marker    Marker
+	Hashes    []string
+}
+
+func (r *Requirement) NormName() string {
+	return Normalize(r.Name)
+}
+
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.'
+}
+
+func ParseRequirement(s string) (*Requirement, error) {
+	rest := strings.TrimSpace(s)
+	i := 0
+	for i < len(rest) && isNameChar(rest[i]) {
+		i++
+	}
+	if i == 0 {
+		return nil, fmt.Errorf("%w %q: missing name", errInvalidRequirement, s)
+	}
+	req := &Requirement{Name: rest[:i]}
+	rest = strings.TrimSpace(rest[i:])
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end == -1 {
+			return nil, fmt.Errorf("%w %q: unterminated extras", errInvalidRequirement, s)
+		}
+		for _, extra := range strings.Split(rest[1:end], ",") {
+			if extra = strings.TrimSpace(extra); extra != "" {
+				req.Extras = append(req.Extras, extra)
+			}
+		}
+		rest = strings.TrimSpace(rest[end+1:])
+	}
+	var marker string
+	if strings.HasPrefix(rest, "@") {
+		rest = strings.TrimSpace(rest[1:])
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end == -1 {
+			end = len(rest)
+		}
+		req.URL = rest[:end]
+		rest = strings.TrimSpace(rest[end:])
+		if rest != "" {
+			if !strings.HasPrefix(rest, ";") {
+				return nil, fmt.Errorf("%w %q: unexpected %q after URL", errInvalidRequirement, s, rest)
+			}
+			marker = rest[1:]
+		}
+	} else {
+		if idx := strings.Index(rest, ";"); idx != -1 {
+			marker = rest[idx+1:]
+			rest = rest[:idx]
+		}
+		spec := strings.TrimSpace(rest)
+		if strings.HasPrefix(spec, "(") && strings.HasSuffix(spec, ")") {
+			spec = strings.TrimSpace(spec[1 : len(spec)-1])
+		}
+		req.Specifier = strings.Join(strings.Fields(spec), "")
+	}
+	if marker != "" {
+		m, err := ParseMarker(marker)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", errInvalidRequirement, s, err)
+		}
+		req.Marker = m
+	}
+	return req, nil
+}
+
+type Marker interface {
+	Evaluate(env map[string]string) (bool, error)
+}
+
+type markerOr []Marker
+
+func (m markerOr) Evaluate(env map[string]string) (bool, error) {
+	for _, sub := range m {
+		ok, err := sub.Evaluate(env)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+type markerAnd []Marker
+
+func (m markerAnd) Evaluate(env map[string]string) (bool, error) {
+	for _, sub := range m {
+		ok, err := sub.Evaluate(env)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+type markerValue struct {
+	variable string
+	literal  string
+}
+
+func (v markerValue) resolve(env map[string]string) (string, error) {
+	if v.variable == "" {
+		return v.literal, nil
+	}
+	value, ok := env[v.variable]
+	if !ok {
+		return "", fmt.Errorf("unknown marker variable %q", v.variable)
+	}
+	return value, nil
+}
+
+type markerCompare struct {
+	lhs markerValue
+	op  string
+	rhs markerValue
+}
+
+func (m *markerCompare) Evaluate(env map[string]string) (bool, error) {
+	lhs, err := m.lhs.resolve(env)
+	if err != nil {
+		return false, err
+	}
+	rhs, err := m.rhs.resolve(env)
+	if err != nil {
+		return false, err
+	}
+	if m.lhs.variable == "extra" || m.rhs.variable == "extra" {
+		lhs, rhs = Normalize(lhs), Normalize(rhs)
+	}
+	switch m.op {
+	case "in":
+		return strings.Contains(rhs, lhs), nil
+	case "not in":
+		return !strings.Contains(rhs, lhs), nil
+	}
+	if ok, err := MatchSpecifier(m.op+rhs, lhs); err == nil {
+		return ok, nil
+	}
+	switch m.op {
+	case "==", "===":
+		return lhs == rhs, nil
+	case "!=":
+		return lhs != rhs, nil
+	case "<":
+		return lhs < rhs, nil
+	case "<=":
+		return lhs <= rhs, nil
+	case ">":
+		return lhs > rhs, nil
+	case ">=":
+		return lhs >= rhs, nil
+	}
+	return false, fmt.Errorf("unable to compare %q and %q with %q", lhs, rhs, m.op)
+}
+
+var markerVariables = map[string]string{
+	"os_name":                        "os_name",
+	"os.name":                        "os_name",
+	"sys_platform":                   "sys_platform",
+	"sys.platform":                   "sys_platform",
+	"platform_machine":               "platform_machine",
+	"platform.machine":               "platform_machine",
+	"platform_python_implementation": "platform_python_implementation",
+	"platform.python_implementation": "platform_python_implementation",
+	"python_implementation":          "platform_python_implementation",
+	"platform_release":               "platform_release",
+	"platform_system":                "platform_system",
+	"platform_version":               "platform_version",
+	"platform.version":               "platform_version",
+	"python_version":                 "python_version",
+	"python_full_version":            "python_full_version",
+	"implementation_name":            "implementation_name",
+	"implementation_version":         "implementation_version",
+	"extra":                          "extra",
+}
+
+type markerParser struct {
+	tokens []string
+	pos    int
+}
+
+func tokenizeMarker(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(s[i+1:], c)
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated string in marker %q", s)
+			}
+			tokens = append(tokens, s[i:i+end+2])
+			i += end + 2
+		case strings.IndexByte("<>=!~", c) != -1:
+			j := i
+			for j < len(s) && strings.IndexByte("<>=!~", s[j]) != -1 {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		case isNameChar(c):
+			j := i
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q in marker %q", c, s)
+		}
+	}
+	return tokens, nil
+}
+
+func ParseMarker(s string) (Marker, error) {
+	tokens, err := tokenizeMarker(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &markerParser{tokens: tokens}
+	m, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in marker %q", p.tokens[p.pos], s)
+	}
+	return m, nil
+}
+
+func (p *markerParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *markerParser) next() string {
+	t := p.peek()
+	if t != "" {
+		p.pos++
+	}
+	return t
+}
+
+func (p *markerParser) parseOr() (Marker, error) {
+	var or markerOr
+	for {
+		m, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, m)
+		if p.peek() != "or" {
+			break
+		}
+		p.next()
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *markerParser) parseAnd() (Marker, error) {
+	var and markerAnd
+	for {
+		m, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, m)
+		if p.peek() != "and" {
+			break
+		}
+		p.next()
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *markerParser) parseExpr() (Marker, error) {
+	if p.peek() == "(" {
+		p.next()
+		m, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t != ")" {
+			return nil, fmt.Errorf("expected %q, got %q", ")", t)
+		}
+		return m, nil
+	}
+	lhs, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	switch op {
+	case "not":
+		if t := p.next(); t != "in" {
+			return nil, fmt.Errorf("expected %q after %q, got %q", "in", "not", t)
+		}
+		op = "not in"
+	case "in", "===", "~=", "==", "!=", "<=", ">=", "<", ">":
+	default:
+		return nil, fmt.Errorf("invalid marker operator %q", op)
+	}
+	rhs, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return &markerCompare{lhs, op, rhs}, nil
+}
+
+func (p *markerParser) parseValue() (markerValue, error) {
+	t := p.next()
+	if t == "" {
+		return markerValue{}, errors.New("unexpected end of marker")
+	}
+	if t[0] == '\'' || t[0] == '"' {
+		return markerValue{literal: t[1 : len(t)-1]}, nil
+	}
+	variable, ok := markerVariables[t]
+	if !ok {
+		return markerValue{}, fmt.Errorf("unknown marker variable %q", t)
+	}
+	return markerValue{variable: variable}, nil
+}
@@ -0,0 +1,134 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ignoredRequirementsOptions = map[string]bool{
+	"-i":                true,
+	"--index-url":       true,
+	"--extra-index-url": true,
+	"--no-index":        true,
+	"-f":                true,
+	"--find-links":      true,
+	"--trusted-host":    true,
+	"--pre":             true,
+	"--prefer-binary":   true,
+	"--only-binary":     true,
+	"--no-binary":       true,
+	"--require-hashes":  true,
+	"--use-feature":     true,
+}
+
+var ignoredRequirementOptions = map[string]bool{
+	"--global-option":   true,
+	"--config-settings": true,
+}
+
+func optionName(field string) string {
+	if strings.HasPrefix(field, "--") {
+		return strings.SplitN(field, "=", 2)[0]
+	}
+	if len(field) > 2 {
+		return field[:2]
+	}
+	return field
+}
+
+func ParseRequirementsFile(path string) ([]*Requirement, error) {
+	return parseRequirementsFile(path, make(map[string]bool))
+}
+
+func parseRequirementsFile(path string, including map[string]bool) ([]*Requirement, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if including[abs] {
+		return nil, fmt.Errorf("%s: recursive inclusion", path)
+	}
+	including[abs] = true
+	defer delete(including, abs)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var reqs []*Requirement
+	var logical string
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if strings.HasSuffix(line, "\\") {
+			logical += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		line = logical + line
+		logical = ""
+		if idx := strings.Index(line, "#"); idx == 0 || idx > 0 && (line[idx-1] == ' ' || line[idx-1] == '\t') {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "-") {
+			var include string
+			switch {
+			case (fields[0] == "-r" || fields[0] == "--requirement") && len(fields) > 1:
+				include = fields[1]
+			case strings.HasPrefix(fields[0], "--requirement="):
+				include = strings.TrimPrefix(fields[0], "--requirement=")
+			case strings.HasPrefix(fields[0], "-r") && len(fields[0]) > 2:
+				include = fields[0][2:]
+			case ignoredRequirementsOptions[optionName(fields[0])]:
+				continue
+			default:
+				return nil, fmt.Errorf("%s:%d: unsupported option %s", path, lineNo, fields[0])
+			}
+			if !filepath.IsAbs(include) {
+				include = filepath.Join(filepath.Dir(path), include)
+			}
+			included, err := parseRequirementsFile(include, including)
+			if err != nil {
+				return nil, err
+			}
+			reqs = append(reqs, included...)
+			continue
+		}
+		spec := make([]string, 0, len(fields))
+		var hashes []string
+		for i := 0; i < len(fields); i++ {
+			switch {
+			case fields[i] == "--hash" && i+1 < len(fields):
+				i++
+				hashes = append(hashes, fields[i])
+			case strings.HasPrefix(fields[i], "--hash="):
+				hashes = append(hashes, strings.TrimPrefix(fields[i], "--hash="))
+			case ignoredRequirementOptions[fields[i]] && i+1 < len(fields):
+				i++
+			case ignoredRequirementOptions[optionName(fields[i])]:
+			case strings.HasPrefix(fields[i], "--"):
+				return nil, fmt.Errorf("%s:%d: unsupported option %s", path, lineNo, fields[i])
+			default:
+				spec = append(spec, fields[i])
+			}
+		}
+		req, err := ParseRequirement(strings.Join(spec, " "))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		req.Hashes = hashes
+		reqs = append(reqs, req)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return reqs, nil
+}
@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeRequirementsFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "requirements")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParseRequirementsFile(t *testing.T) {
+	dir := writeRequirementsFiles(t, map[string]string{
+		"requirements.txt": "--index-url https://example.com/simple\n" +
+			"-r base.txt\n" +
+			"foo==1.0 \\\n    --hash=sha256:aaaa --hash sha256:bbbb # pinned\n" +
+			"bar>=2 --config-settings key=value\n",
+		"base.txt":   "--requirement=common.txt\nbaz\n",
+		"common.txt": "# shared by base.txt and requirements.txt\nqux<3\n",
+	})
+	defer os.RemoveAll(dir)
+	reqs, err := ParseRequirementsFile(filepath.Join(dir, "requirements.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range reqs {
+		got = append(got, r.Name+"["+strings.Join(r.Hashes, ",")+"]")
+	}
+	want := "qux[] baz[] foo[sha256:aaaa,sha256:bbbb] bar[]"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, " "), want)
+	}
+}
+
+func TestParseRequirementsFileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"self inclusion", map[string]string{"requirements.txt": "-r requirements.txt\n"}},
+		{"indirect cycle", map[string]string{"requirements.txt": "-r a.txt\n", "a.txt": "-ra/../b.txt\n", "b.txt": "-r requirements.txt\n"}},
+		{"constraints", map[string]string{"requirements.txt": "-c constraints.txt\nfoo\n", "constraints.txt": "foo<2\n"}},
+		{"editable", map[string]string{"requirements.txt": "-e .\n"}},
+		{"unknown option", map[string]string{"requirements.txt": "--frobnicate\n"}},
+		{"unknown requirement option", map[string]string{"requirements.txt": "foo --frobnicate\n"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeRequirementsFiles(t, test.files)
+			defer os.RemoveAll(dir)
+			if _, err := ParseRequirementsFile(filepath.Join(dir, "requirements.txt")); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -3,31 +3,52 @@ package pkg
 import (
 	"sort"
 
-	"github.com/hashicorp/go-version"
+	"github.com/montag451/go-pypi-mirror/internal/pep440"
 
 	"golang.org/x/text/collate"
 	"golang.org/x/text/language"
 )
 
+type byVersion struct {
+	pkgs     []*Pkg
+	versions []*pep440.Version
+}
+
+func (b *byVersion) Len() int {
+	return len(b.pkgs)
+}
+
+func (b *byVersion) Less(i, j int) bool {
+	v1, v2 := b.versions[i], b.versions[j]
+	switch {
+	case v1 == nil && v2 == nil:
+		return b.pkgs[i].Metadata.Version < b.pkgs[j].Metadata.Version
+	case v1 == nil || v2 == nil:
+		return v1 == nil
+	}
+	if c := v1.Compare(v2); c != 0 {
+		return c < 0
+	}
+	return b.pkgs[i].Metadata.Version < b.pkgs[j].Metadata.Version
+}
+
+func (b *byVersion) Swap(i, j int) {
+	b.pkgs[i], b.pkgs[j] = b.pkgs[j], b.pkgs[i]
+	b.versions[i], b.versions[j] = b.versions[j], b.versions[i]
+}
+
 func SortByVersion(pkgs []*Pkg, desc bool) {
-	version := func(i, j int) bool {
-		p1, p2 := pkgs[i], pkgs[j]
-		v1, err1 := version.NewVersion(p1.Metadata.Version)
-		v2, err2 := version.NewVersion(p2.Metadata.Version)
-		if err1 != nil || err2 != nil {
-			if desc {
-				return p1.Metadata.Version > p2.Metadata.Version
-			} else {
-				return p1.Metadata.Version < p2.Metadata.Version
-			}
-		}
-		if desc {
-			return v1.Compare(v2) == 1
-		} else {
-			return v1.Compare(v2) == -1
+	b := &byVersion{pkgs, make([]*pep440.Version, len(pkgs))}
+	for i, p := range pkgs {
+		if v, err := pep440.Parse(p.Metadata.Version); err == nil {
+			b.versions[i] = v
 		}
 	}
-	sort.Slice(pkgs, version)
+	if desc {
+		sort.Sort(sort.Reverse(b))
+	} else {
+		sort.Sort(b)
+	}
 }
 
 func SortByNormName(pkgs []*Pkg, desc bool) {
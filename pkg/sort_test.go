@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestSortByVersion(t *testing.T) {
+	want := []string{"", "foo", "latest", "1.0.dev0", "1.0a1", "1.0", "1.0.0", "1.0.post1", "1.10", "2!0.1"}
+	for seed := int64(0); seed < 20; seed++ {
+		pkgs := make([]*Pkg, len(want))
+		for i, v := range rand.New(rand.NewSource(seed)).Perm(len(want)) {
+			pkgs[i] = &Pkg{Metadata: &Metadata{Version: want[v]}}
+		}
+		SortByVersion(pkgs, false)
+		if got := versions(pkgs); got != strings.Join(want, " ") {
+			t.Fatalf("seed %d: got %q, want %q", seed, got, strings.Join(want, " "))
+		}
+		SortByVersion(pkgs, true)
+		reversed := make([]string, len(want))
+		for i, v := range want {
+			reversed[len(want)-1-i] = v
+		}
+		if got := versions(pkgs); got != strings.Join(reversed, " ") {
+			t.Fatalf("seed %d: got %q, want %q", seed, got, strings.Join(reversed, " "))
+		}
+	}
+}
+
+func TestGroupByVersionEquivalentSpellings(t *testing.T) {
+	raw := []string{"1.0", "1.0.0", "1.0", "1.0.0", "1.0", "1.0.0", "1.0"}
+	for seed := int64(0); seed < 20; seed++ {
+		pkgs := make([]*Pkg, len(raw))
+		for i, v := range rand.New(rand.NewSource(seed)).Perm(len(raw)) {
+			pkgs[i] = &Pkg{Metadata: &Metadata{Version: raw[v]}}
+		}
+		groups := GroupByVersion(pkgs)
+		if len(groups) != 2 || groups[0].Key != "1.0" || len(groups[0].Pkgs) != 4 || groups[1].Key != "1.0.0" || len(groups[1].Pkgs) != 3 {
+			t.Fatalf("seed %d: unexpected groups %q", seed, versions(pkgs))
+		}
+	}
+}
+
+func versions(pkgs []*Pkg) string {
+	var vs []string
+	for _, p := range pkgs {
+		vs = append(vs, p.Metadata.Version)
+	}
+	return strings.Join(vs, " ")
+}
@@ -0,0 +1,22 @@
+package pkg
+
+import (
+	"fmt"
+
+	"github.com/montag451/go-pypi-mirror/internal/pep440"
+)
+
+func MatchSpecifier(specifier string, rawVersion string) (bool, error) {
+	specs, err := pep440.ParseSpecifierSet(specifier)
+	if err != nil {
+		return false, err
+	}
+	if len(specs) == 0 {
+		return true, nil
+	}
+	v, err := pep440.Parse(rawVersion)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse version %q: %w", rawVersion, err)
+	}
+	return specs.Contains(v, true), nil
+}
@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	manylinuxRegex = regexp.MustCompile(`^manylinux_(\d+)_(\d+)_(.+)$`)
+	musllinuxRegex = regexp.MustCompile(`^musllinux_(\d+)_(\d+)_(.+)$`)
+	legacyRegex    = regexp.MustCompile(`^(manylinux1|manylinux2010|manylinux2014)_(.+)$`)
+)
+
+var legacyManylinux = map[string]int{
+	"manylinux1":    5,
+	"manylinux2010": 12,
+	"manylinux2014": 17,
+}
+
+type TagSet map[Tag]bool
+
+func (s TagSet) Supports(tags []Tag) bool {
+	for _, t := range tags {
+		if s[t] {
+			return true
+		}
+	}
+	return false
+}
+
+func ParsePythonVersion(s string) (int, int, error) {
+	var major, minor string
+	if components := strings.Split(s, "."); len(components) > 1 {
+		major, minor = components[0], components[1]
+	} else if len(s) > 1 {
+		major, minor = s[:1], s[1:]
+	} else {
+		major, minor = s, "0"
+	}
+	ma, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Python version %q", s)
+	}
+	mi, err := strconv.Atoi(minor)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Python version %q", s)
+	}
+	return ma, mi, nil
+}
+
+func expandPlatform(platform string) []string {
+	glibc := -1
+	var arch string
+	if m := legacyRegex.FindStringSubmatch(platform); m != nil {
+		glibc, arch = legacyManylinux[m[1]], m[2]
+	} else if m := manylinuxRegex.FindStringSubmatch(platform); m != nil && m[1] == "2" {
+		glibc, _ = strconv.Atoi(m[2])
+		arch = m[3]
+	} else if m := musllinuxRegex.FindStringSubmatch(platform); m != nil {
+		minor, _ := strconv.Atoi(m[2])
+		platforms := make([]string, 0, minor+1)
+		for i := minor; i >= 0; i-- {
+			platforms = append(platforms, fmt.Sprintf("musllinux_%s_%d_%s", m[1], i, m[3]))
+		}
+		return platforms
+	}
+	if glibc == -1 {
+		return []string{platform}
+	}
+	var platforms []string
+	for i := glibc; i >= 5; i-- {
+		platforms = append(platforms, fmt.Sprintf("manylinux_2_%d_%s", i, arch))
+		for legacy, v := range legacyManylinux {
+			if v == i {
+				platforms = append(platforms, legacy+"_"+arch)
+			}
+		}
+	}
+	return platforms
+}
+
+func SupportedTags(pythonVersion, implementation string, abis, platforms []string) (TagSet, error) {
+	major, minor, err := ParsePythonVersion(pythonVersion)
+	if err != nil {
+		return nil, err
+	}
+	if implementation == "" {
+		implementation = "cp"
+	}
+	interpreter := fmt.Sprintf("%s%d%d", implementation, major, minor)
+	if len(abis) == 0 && implementation == "cp" {
+		abis = []string{interpreter}
+	}
+	var expanded []string
+	for _, p := range platforms {
+		expanded = append(expanded, expandPlatform(p)...)
+	}
+	if len(expanded) == 0 {
+		expanded = []string{"any"}
+	}
+	tags := make(TagSet)
+	add := func(python, abi string, platforms []string) {
+		for _, platform := range platforms {
+			tags[Tag{python, abi, platform}] = true
+		}
+	}
+	for _, abi := range abis {
+		add(interpreter, abi, expanded)
+	}
+	add(interpreter, "none", expanded)
+	if implementation == "cp" {
+		for m := minor; m >= 2; m-- {
+			add(fmt.Sprintf("cp%d%d", major, m), "abi3", expanded)
+		}
+	}
+	pythons := []string{fmt.Sprintf("py%d%d", major, minor), fmt.Sprintf("py%d", major)}
+	for m := minor - 1; m >= 0; m-- {
+		pythons = append(pythons, fmt.Sprintf("py%d%d", major, m))
+	}
+	for _, python := range pythons {
+		add(python, "none", expanded)
+		add(python, "none", []string{"any"})
+	}
+	add(interpreter, "none", []string{"any"})
+	return tags, nil
+}
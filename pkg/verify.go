@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/montag451/go-pypi-mirror/internal/pool"
+)
+
+type Problem int
+
+const (
+	ProblemMissingMetadata Problem = iota + 1
+	ProblemHashMismatch
+	ProblemOrphanedMetadata
+	ProblemCorruptMetadata
+)
+
+func (p Problem) String() string {
+	switch p {
+	case ProblemMissingMetadata:
+		return "missing metadata"
+	case ProblemHashMismatch:
+		return "hash mismatch"
+	case ProblemOrphanedMetadata:
+		return "orphaned metadata"
+	case ProblemCorruptMetadata:
+		return "corrupt metadata"
+	}
+	return "unknown problem"
+}
+
+type Finding struct {
+	Path     string
+	Problem  Problem
+	Expected string
+	Actual   string
+	Err      error
+}
+
+func Verify(ctx context.Context, dir string, workers int) ([]*Finding, error) {
+	var paths []string
+	var findings []*Finding
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+		if strings.HasSuffix(path, MetadataExt) {
+			if _, err := os.Stat(strings.TrimSuffix(path, MetadataExt)); errors.Is(err, os.ErrNotExist) {
+				findings = append(findings, &Finding{Path: path, Problem: ProblemOrphanedMetadata})
+			}
+			return nil
+		}
+		if IsSupported(filepath.Base(path)) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*Finding, len(paths))
+	err = pool.Run(ctx, len(paths), workers, func(i int) error {
+		meta, err := ReadMetadataFile(paths[i])
+		if err != nil {
+			results[i] = &Finding{Path: paths[i], Problem: ProblemCorruptMetadata, Err: err}
+			return nil
+		}
+		if meta == nil {
+			results[i] = &Finding{Path: paths[i], Problem: ProblemMissingMetadata}
+			return nil
+		}
+		hash, err := HashFile(paths[i])
+		if err != nil {
+			return err
+		}
+		if hash != meta.Hash {
+			results[i] = &Finding{Path: paths[i], Problem: ProblemHashMismatch, Expected: meta.Hash, Actual: hash}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range results {
+		if f != nil {
+			findings = append(findings, f)
+		}
+	}
+	return findings, nil
+}
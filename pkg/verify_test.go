@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"archive/zip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func writeTestWheel(t *testing.T, dir, name, version string) string {
+	t.Helper()
+	path := filepath.Join(dir, name+"-"+version+"-py3-none-any.whl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	m, err := w.Create(name + "-" + version + ".dist-info/METADATA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Write([]byte("Metadata-Version: 2.1\nName: " + name + "\nVersion: " + version + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, version := range []string{"1.0", "2.0", "3.0"} {
+		p, err := New(writeTestWheel(t, dir, "foo", version))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteMetadataFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing := writeTestWheel(t, dir, "foo", "4.0")
+	corrupt := filepath.Join(dir, "foo-2.0-py3-none-any.whl")
+	if err := ioutil.WriteFile(corrupt+MetadataExt, []byte(`{"name": "foo", `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	orphaned := filepath.Join(dir, "foo-3.0-py3-none-any.whl")
+	if err := os.Remove(orphaned); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{".foo-5.0-py3-none-any.whl", ".foo-5.0-py3-none-any.whl.part", ".foo-6.0-py3-none-any.whl" + MetadataExt} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("partial"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	findings, err := Verify(context.Background(), dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].Path < findings[j].Path
+	})
+	want := []struct {
+		path    string
+		problem Problem
+	}{
+		{corrupt, ProblemCorruptMetadata},
+		{orphaned + MetadataExt, ProblemOrphanedMetadata},
+		{missing, ProblemMissingMetadata},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("got %d findings, want %d", len(findings), len(want))
+	}
+	for i, w := range want {
+		if findings[i].Path != w.path || findings[i].Problem != w.problem {
+			t.Errorf("finding %d = %s: %v, want %s: %v", i, findings[i].Path, findings[i].Problem, w.path, w.problem)
+		}
+	}
+	if findings[0].Err == nil {
+		t.Errorf("corrupt metadata finding should carry the decoding error")
+	}
+}
@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Kind string
+
+const (
+	KindSdist Kind = "sdist"
+	KindWheel Kind = "wheel"
+)
+
+const wheelExt = ".whl"
+
+func KindOf(filename string) Kind {
+	if strings.HasSuffix(filename, wheelExt) {
+		return KindWheel
+	}
+	return KindSdist
+}
+
+type Tag struct {
+	Python   string
+	ABI      string
+	Platform string
+}
+
+func (t Tag) String() string {
+	return t.Python + "-" + t.ABI + "-" + t.Platform
+}
+
+type WheelInfo struct {
+	Name         string
+	Version      string
+	BuildTag     string
+	PythonTags   []string
+	ABITags      []string
+	PlatformTags []string
+}
+
+func ParseWheelFilename(filename string) (*WheelInfo, error) {
+	if !strings.HasSuffix(filename, wheelExt) {
+		return nil, fmt.Errorf("%w %q", errInvalidArchiveName, filename)
+	}
+	components := strings.Split(strings.TrimSuffix(filename, wheelExt), "-")
+	if len(components) != 5 && len(components) != 6 {
+		return nil, fmt.Errorf("%w %q", errInvalidArchiveName, filename)
+	}
+	info := &WheelInfo{
+		Name:    components[0],
+		Version: components[1],
+	}
+	if len(components) == 6 {
+		info.BuildTag = components[2]
+		components = append(components[:2], components[3:]...)
+	}
+	info.PythonTags = strings.Split(components[2], ".")
+	info.ABITags = strings.Split(components[3], ".")
+	info.PlatformTags = strings.Split(components[4], ".")
+	return info, nil
+}
+
+func (m *Metadata) setWheelTags(filename string) error {
+	info, err := ParseWheelFilename(filename)
+	if err != nil {
+		return err
+	}
+	m.BuildTag = info.BuildTag
+	m.PythonTags = info.PythonTags
+	m.ABITags = info.ABITags
+	m.PlatformTags = info.PlatformTags
+	return nil
+}
+
+func expandTags(pythonTags, abiTags, platformTags []string) []Tag {
+	tags := make([]Tag, 0, len(pythonTags)*len(abiTags)*len(platformTags))
+	for _, python := range pythonTags {
+		for _, abi := range abiTags {
+			for _, platform := range platformTags {
+				tags = append(tags, Tag{python, abi, platform})
+			}
+		}
+	}
+	return tags
+}
+
+func (w *WheelInfo) Tags() []Tag {
+	return expandTags(w.PythonTags, w.ABITags, w.PlatformTags)
+}
+
+func (m *Metadata) Tags() []Tag {
+	return expandTags(m.PythonTags, m.ABITags, m.PlatformTags)
+}